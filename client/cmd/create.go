@@ -10,9 +10,15 @@ import (
 )
 
 var (
-	url        string
-	customCode string
-	expiresIn  int
+	url          string
+	customCode   string
+	expiresIn    int
+	redirectType int
+	linkPassword string
+	maxClicks    int64
+	expiresAt    string
+	activatesAt  string
+	fallbackURL  string
 )
 
 var createCmd = &cobra.Command{
@@ -20,20 +26,32 @@ var createCmd = &cobra.Command{
 	Short: "Create short link",
 	Long:  `Create a new short link, can automatically generate short code or use custom short code.`,
 	Run: func(_ *cobra.Command, _ []string) {
-		var c *client.Client
-		if insecureSkipVerify {
-			c = client.NewClientWithInsecureSkipVerify(baseURL)
-			if verbose {
-				color.Yellow("⚠ Warning: Skipping TLS certificate verification")
-			}
-		} else {
-			c = client.NewClient(baseURL)
+		c := newClient()
+		if insecureSkipVerify && verbose {
+			color.Yellow("⚠ Warning: Skipping TLS certificate verification")
+		}
+
+		expiry, err := parseTimeFlag(expiresAt)
+		if err != nil {
+			color.Red("✗ Invalid --expires-at: %v", err)
+			return
+		}
+		activation, err := parseTimeFlag(activatesAt)
+		if err != nil {
+			color.Red("✗ Invalid --activates-at: %v", err)
+			return
 		}
 
 		req := client.CreateShortCodeRequest{
-			URL:        url,
-			CustomCode: customCode,
-			ExpiresIn:  expiresIn,
+			URL:          url,
+			CustomCode:   customCode,
+			ExpiresIn:    expiresIn,
+			RedirectType: redirectType,
+			Password:     linkPassword,
+			MaxClicks:    maxClicks,
+			ExpiresAt:    expiry,
+			ActivatesAt:  activation,
+			FallbackURL:  fallbackURL,
 		}
 
 		color.Cyan("Creating short link...")
@@ -45,6 +63,21 @@ var createCmd = &cobra.Command{
 			if expiresIn > 0 {
 				color.Yellow("Expiration time: %d hours", expiresIn)
 			}
+			if expiry != nil {
+				color.Yellow("Expires at: %s", expiry.Format(time.RFC3339))
+			}
+			if activation != nil {
+				color.Yellow("Activates at: %s", activation.Format(time.RFC3339))
+			}
+			if redirectType > 0 {
+				color.Yellow("Redirect type: %d", redirectType)
+			}
+			if linkPassword != "" {
+				color.Yellow("Password protected: yes")
+			}
+			if maxClicks > 0 {
+				color.Yellow("Max clicks: %d", maxClicks)
+			}
 		}
 
 		resp, err := c.CreateShortCode(req)
@@ -58,10 +91,23 @@ var createCmd = &cobra.Command{
 		color.Cyan("Short code:      %s", resp.ShortCode)
 		color.Cyan("Short link:    %s", resp.ShortURL)
 		color.Cyan("Original URL:   %s", resp.OriginalURL)
+		color.Cyan("Redirect type: %d", resp.RedirectType)
 		color.Cyan("Created at:  %s", resp.CreatedAt.Format(time.RFC3339))
+		if resp.ActivatesAt != nil {
+			color.Cyan("Activates at: %s", resp.ActivatesAt.Format(time.RFC3339))
+		}
 		if resp.ExpiresAt != nil {
 			color.Cyan("Expires at:  %s", resp.ExpiresAt.Format(time.RFC3339))
 		}
+		if resp.PasswordProtected {
+			color.Cyan("Password protected: yes")
+		}
+		if resp.MaxClicks != nil {
+			color.Cyan("Max clicks:  %d", *resp.MaxClicks)
+		}
+		if resp.FallbackURL != "" {
+			color.Cyan("Fallback URL: %s", resp.FallbackURL)
+		}
 		fmt.Println()
 	},
 }
@@ -72,6 +118,12 @@ func init() {
 	createCmd.Flags().StringVarP(&url, "long-url", "l", "", "The long URL to shorten (required)")
 	createCmd.Flags().StringVarP(&customCode, "code", "c", "", "Custom short code (optional, auto-generated if not provided)")
 	createCmd.Flags().IntVarP(&expiresIn, "expires", "e", 0, "Expiration time (hours, optional)")
+	createCmd.Flags().StringVar(&expiresAt, "expires-at", "", "Absolute expiration time, RFC 3339 e.g. 2025-12-31T23:59:00+01:00 (optional, instead of --expires)")
+	createCmd.Flags().StringVar(&activatesAt, "activates-at", "", "Time the link starts redirecting, RFC 3339 (optional)")
+	createCmd.Flags().StringVar(&fallbackURL, "fallback-url", "", "Destination once the link is expired, exhausted or disabled (optional)")
+	createCmd.Flags().IntVarP(&redirectType, "redirect-type", "r", 0, "Redirect status code: 301, 302, 307 or 308 (optional, service default if not provided)")
+	createCmd.Flags().StringVarP(&linkPassword, "password", "p", "", "Password required to follow the link (optional, 4-72 characters)")
+	createCmd.Flags().Int64VarP(&maxClicks, "max-clicks", "m", 0, "Number of redirects before the link stops working, 1 for a one-time link (optional)")
 
 	if err := createCmd.MarkFlagRequired("long-url"); err != nil {
 		panic(fmt.Sprintf("failed to mark flag as required: %v", err))
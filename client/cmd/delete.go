@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"github.com/fatih/color"
-	"github.com/lincyaw/tools/client/pkg/client"
 	"github.com/spf13/cobra"
 )
 
@@ -13,12 +12,7 @@ var deleteCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(_ *cobra.Command, args []string) {
 		code := args[0]
-		var c *client.Client
-		if insecureSkipVerify {
-			c = client.NewClientWithInsecureSkipVerify(baseURL)
-		} else {
-			c = client.NewClient(baseURL)
-		}
+		c := newClient()
 
 		color.Cyan("Deleting short code '%s'...", code)
 
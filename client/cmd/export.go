@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/lincyaw/tools/client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportType            string
+	exportFormat          string
+	exportOutput          string
+	exportFrom            string
+	exportTo              string
+	exportGranularity     string
+	exportTimezone        string
+	exportExcludeInternal bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [short code]",
+	Short: "Export click data to a file",
+	Long: `Export the raw click logs or the click time series of a short link as CSV or
+NDJSON. The export is streamed to the file, so it can cover any number of clicks.
+
+The format follows the extension of --output unless --format is given. Without
+--output the file is named after the short code, e.g. promo1-clicks.csv; use
+--output - to write to stdout.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		code := args[0]
+
+		if exportType != "clicks" && exportType != "timeseries" {
+			color.Red("✗ Unknown export type '%s', use --type clicks or --type timeseries", exportType)
+			return
+		}
+
+		format := exportFormat
+		if format == "" && exportOutput != "" && exportOutput != "-" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(exportOutput)), ".")
+		}
+		if format == "jsonl" {
+			format = "ndjson"
+		}
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "ndjson" {
+			color.Red("✗ Unknown format '%s', use --format csv or --format ndjson", format)
+			return
+		}
+
+		output := exportOutput
+		if output == "" {
+			output = fmt.Sprintf("%s-%s.%s", code, exportType, format)
+		}
+
+		opts := client.ExportOptions{
+			DetailedStatsOptions: client.DetailedStatsOptions{
+				From:            exportFrom,
+				To:              exportTo,
+				Granularity:     exportGranularity,
+				Timezone:        exportTimezone,
+				ExcludeInternal: exportExcludeInternal,
+			},
+			Format: format,
+		}
+
+		c := newClient()
+		export := c.ExportClicks
+		if exportType == "timeseries" {
+			export = c.ExportTimeSeries
+		}
+
+		if output == "-" {
+			if _, err := export(code, opts, os.Stdout); err != nil {
+				color.Red("✗ Failed to export: %v", err)
+			}
+			return
+		}
+
+		written, err := exportToFile(output, func(w io.Writer) (int64, error) {
+			return export(code, opts, w)
+		})
+		if err != nil {
+			color.Red("✗ Failed to export: %v", err)
+			return
+		}
+		color.Green("✓ Exported %s of '%s' to %s (%d bytes)", exportType, code, output, written)
+	},
+}
+
+// exportToFile writes the export next to the output file and moves it into place once it is
+// complete, so a failed export does not leave a truncated file behind
+func exportToFile(path string, export func(w io.Writer) (int64, error)) (int64, error) {
+	partial := path + ".part"
+	file, err := os.Create(partial)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := export(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return written, err
+	}
+	return written, os.Rename(partial, path)
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportType, "type", "t", "clicks", "What to export: clicks (raw click logs) or timeseries")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "", "csv or ndjson (default from the --output extension, else csv)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file, - for stdout (default <code>-<type>.<format>)")
+	exportCmd.Flags().StringVar(&exportFrom, "from", "", "Start of the range, RFC 3339 or YYYY-MM-DD")
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "End of the range, RFC 3339 or YYYY-MM-DD, the whole day included")
+	exportCmd.Flags().StringVarP(&exportGranularity, "granularity", "g", "", "Time series buckets: minute, hour, day, week or month")
+	exportCmd.Flags().StringVar(&exportTimezone, "tz", "", "IANA time zone of dates, times and buckets, e.g. Europe/Berlin (default UTC)")
+	exportCmd.Flags().BoolVarP(&exportExcludeInternal, "exclude-internal", "x", false, "Leave out clicks from private, loopback and reserved IP addresses")
+}
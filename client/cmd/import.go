@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/lincyaw/tools/client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFormat    string
+	importBatchSize int
+	importPartial   bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Create short links from a file",
+	Long: `Create short links in bulk from a CSV or NDJSON file.
+
+CSV files contain the columns url, custom_code, expires_in, redirect_type,
+password, max_clicks, expires_at, activates_at (RFC 3339) and fallback_url. A
+header row is optional; without it the columns are read in that order and only
+url is required.
+NDJSON files contain one create request per line, e.g.
+  {"url": "https://example.com", "custom_code": "promo1", "expires_in": 24}
+
+Links are sent in batches. By default each batch is atomic, use --partial to
+create the valid links of a batch even if others fail.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		path := args[0]
+
+		format := importFormat
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		}
+		if format == "jsonl" {
+			format = "ndjson"
+		}
+		if format != "csv" && format != "ndjson" {
+			color.Red("✗ Unknown format '%s', use --format csv or --format ndjson", format)
+			return
+		}
+		if importBatchSize < 1 || importBatchSize > 1000 {
+			color.Red("✗ --batch-size must be between 1 and 1000")
+			return
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			color.Red("✗ Failed to open file: %v", err)
+			return
+		}
+		defer file.Close()
+
+		var items []client.CreateShortCodeRequest
+		if format == "csv" {
+			items, err = readCSVRequests(file)
+		} else {
+			items, err = readNDJSONRequests(file)
+		}
+		if err != nil {
+			color.Red("✗ Failed to read %s: %v", path, err)
+			return
+		}
+		if len(items) == 0 {
+			color.Yellow("No URLs found in %s", path)
+			return
+		}
+
+		c := newClient()
+		color.Cyan("Importing %d URL(s) in batches of %d...", len(items), importBatchSize)
+
+		succeeded, failed := 0, 0
+		for start := 0; start < len(items); start += importBatchSize {
+			end := min(start+importBatchSize, len(items))
+
+			resp, err := c.CreateShortCodesBatch(client.BatchCreateRequest{
+				Items:   items[start:end],
+				Partial: importPartial,
+			})
+			if err != nil {
+				color.Red("✗ Batch %d-%d failed: %v", start+1, end, err)
+				failed += end - start
+				continue
+			}
+
+			succeeded += resp.Succeeded
+			failed += resp.Failed
+			for _, result := range resp.Results {
+				row := start + result.Index + 1
+				if result.Success {
+					if verbose {
+						color.Green("  %d: %s -> %s", row, result.Result.ShortURL, result.Result.OriginalURL)
+					}
+					continue
+				}
+				color.Red("  %d: %s - %s (%s)", row, result.Error, result.Message, items[start+result.Index].URL)
+			}
+		}
+
+		fmt.Println()
+		if failed == 0 {
+			color.Green("✓ Imported %d short link(s)", succeeded)
+		} else {
+			color.Yellow("Imported %d short link(s), %d failed", succeeded, failed)
+		}
+	},
+}
+
+// readCSVRequests reads create requests from CSV, with or without a header row
+func readCSVRequests(r io.Reader) ([]client.CreateShortCodeRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{"url": 0, "custom_code": 1, "expires_in": 2, "redirect_type": 3, "password": 4, "max_clicks": 5, "expires_at": 6, "activates_at": 7, "fallback_url": 8}
+	if isHeader(records[0]) {
+		columns = make(map[string]int)
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["url"]; !ok {
+			return nil, errors.New("header row has no url column")
+		}
+		records = records[1:]
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	items := make([]client.CreateShortCodeRequest, 0, len(records))
+	for line, record := range records {
+		longURL := field(record, "url")
+		if longURL == "" {
+			continue
+		}
+
+		item := client.CreateShortCodeRequest{
+			URL:         longURL,
+			CustomCode:  field(record, "custom_code"),
+			Password:    field(record, "password"),
+			FallbackURL: field(record, "fallback_url"),
+		}
+		if expires := field(record, "expires_in"); expires != "" {
+			hours, err := strconv.Atoi(expires)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid expires_in %q", line+1, expires)
+			}
+			item.ExpiresIn = hours
+		}
+		if redirect := field(record, "redirect_type"); redirect != "" {
+			statusCode, err := strconv.Atoi(redirect)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid redirect_type %q", line+1, redirect)
+			}
+			item.RedirectType = statusCode
+		}
+		if limit := field(record, "max_clicks"); limit != "" {
+			clicks, err := strconv.ParseInt(limit, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid max_clicks %q", line+1, limit)
+			}
+			item.MaxClicks = clicks
+		}
+		if item.ExpiresAt, err = parseTimeFlag(field(record, "expires_at")); err != nil {
+			return nil, fmt.Errorf("row %d: invalid expires_at: %w", line+1, err)
+		}
+		if item.ActivatesAt, err = parseTimeFlag(field(record, "activates_at")); err != nil {
+			return nil, fmt.Errorf("row %d: invalid activates_at: %w", line+1, err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// isHeader reports whether a CSV row looks like a header row
+func isHeader(record []string) bool {
+	for _, value := range record {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "url", "custom_code", "expires_in", "redirect_type", "password", "max_clicks", "expires_at", "activates_at", "fallback_url":
+			return true
+		}
+	}
+	return false
+}
+
+// readNDJSONRequests reads one create request per line, blank lines are skipped
+func readNDJSONRequests(r io.Reader) ([]client.CreateShortCodeRequest, error) {
+	var items []client.CreateShortCodeRequest
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var item client.CreateShortCodeRequest
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		items = append(items, item)
+	}
+
+	return items, scanner.Err()
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "", "File format: csv or ndjson (default: from file extension)")
+	importCmd.Flags().IntVarP(&importBatchSize, "batch-size", "b", 500, "Number of URLs per request (1-1000)")
+	importCmd.Flags().BoolVar(&importPartial, "partial", false, "Create valid links even if other links of the batch fail")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lincyaw/tools/client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyName  string
+	keyAdmin bool
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage API keys",
+	Long:  `Create and revoke API keys. Requires an admin API key.`,
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create API key",
+	Long:  `Create a new API key. The key is only shown once, store it safely.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		c := newClient()
+
+		color.Cyan("Creating API key '%s'...", keyName)
+
+		resp, err := c.CreateAPIKey(client.CreateAPIKeyRequest{
+			Name:    keyName,
+			IsAdmin: keyAdmin,
+		})
+		if err != nil {
+			color.Red("✗ Creation failed: %v", err)
+			return
+		}
+
+		color.Green("\n✓ API key created successfully!")
+		fmt.Println()
+		color.Cyan("ID:          %d", resp.ID)
+		color.Cyan("Name:        %s", resp.Name)
+		color.Cyan("Admin:       %t", resp.IsAdmin)
+		color.Cyan("Created at:  %s", resp.CreatedAt.Format(time.RFC3339))
+		color.Yellow("Key:         %s", resp.Key)
+		fmt.Println()
+		color.Yellow("⚠ This key will not be shown again")
+	},
+}
+
+var keysRevokeCmd = &cobra.Command{
+	Use:   "revoke [key id]",
+	Short: "Revoke API key",
+	Long:  `Revoke the API key with the specified ID.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			color.Red("✗ Invalid key ID: %s", args[0])
+			return
+		}
+
+		c := newClient()
+
+		color.Cyan("Revoking API key %d...", id)
+
+		if err := c.RevokeAPIKey(uint(id)); err != nil {
+			color.Red("✗ Revocation failed: %v", err)
+			return
+		}
+
+		color.Green("✓ API key %d revoked successfully!", id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysCreateCmd)
+	keysCmd.AddCommand(keysRevokeCmd)
+
+	keysCreateCmd.Flags().StringVarP(&keyName, "name", "n", "", "Name of the API key (required)")
+	keysCreateCmd.Flags().BoolVar(&keyAdmin, "admin", false, "Create an admin key")
+
+	if err := keysCreateCmd.MarkFlagRequired("name"); err != nil {
+		panic(fmt.Sprintf("failed to mark flag as required: %v", err))
+	}
+}
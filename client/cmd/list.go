@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lincyaw/tools/client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listLimit         int
+	listAll           bool
+	listCursor        string
+	listStatus        string
+	listQuery         string
+	listSort          string
+	listOrder         string
+	listHasClicks     bool
+	listOwner         uint
+	listCreatedAfter  string
+	listCreatedBefore string
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List short links",
+	Long: `List short links owned by the API key (admin keys see all links).
+
+Without --all only one page is shown, continue with --cursor.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		opts := client.ListOptions{
+			Cursor: listCursor,
+			Limit:  listLimit,
+			Status: listStatus,
+			Query:  listQuery,
+			Sort:   listSort,
+			Order:  listOrder,
+		}
+		if cmd.Flags().Changed("has-clicks") {
+			opts.HasClicks = &listHasClicks
+		}
+		if cmd.Flags().Changed("owner") {
+			opts.OwnerID = &listOwner
+		}
+
+		var err error
+		if opts.CreatedAfter, err = parseTimeFlag(listCreatedAfter); err != nil {
+			color.Red("✗ Invalid --created-after: %v", err)
+			return
+		}
+		if opts.CreatedBefore, err = parseTimeFlag(listCreatedBefore); err != nil {
+			color.Red("✗ Invalid --created-before: %v", err)
+			return
+		}
+
+		c := newClient()
+
+		var items []client.ShortCodeInfo
+		nextCursor := ""
+		if listAll {
+			it := c.IterateShortCodes(opts)
+			for it.Next() {
+				items = append(items, it.Item())
+			}
+			if err := it.Err(); err != nil {
+				color.Red("✗ Failed to list: %v", err)
+				return
+			}
+		} else {
+			resp, err := c.ListShortCodes(opts)
+			if err != nil {
+				color.Red("✗ Failed to list: %v", err)
+				return
+			}
+			items = resp.Items
+			nextCursor = resp.NextCursor
+		}
+
+		if len(items) == 0 {
+			color.Yellow("No short links found")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CODE\tCLICKS\tCREATED\tEXPIRES\tURL")
+		for _, item := range items {
+			expires := "never"
+			if item.ExpiresAt != nil {
+				expires = item.ExpiresAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+				item.Code,
+				item.ClickCount,
+				item.CreatedAt.Format("2006-01-02 15:04"),
+				expires,
+				truncate(item.OriginalURL, 60))
+		}
+		if err := w.Flush(); err != nil {
+			color.Red("✗ Failed to write output: %v", err)
+			return
+		}
+
+		fmt.Println()
+		color.Cyan("%d short link(s)", len(items))
+		if nextCursor != "" {
+			color.Cyan("More results available, continue with: --cursor %s", nextCursor)
+		}
+	},
+}
+
+// parseTimeFlag parses an optional RFC 3339 flag value
+func parseTimeFlag(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// truncate shortens a string for table output
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-3] + "..."
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().IntVarP(&listLimit, "limit", "n", 20, "Page size (1-100)")
+	listCmd.Flags().BoolVar(&listAll, "all", false, "Walk through all pages")
+	listCmd.Flags().StringVar(&listCursor, "cursor", "", "Continue from a previous page")
+	listCmd.Flags().StringVar(&listStatus, "status", "", "Filter by status: active, scheduled or expired")
+	listCmd.Flags().StringVarP(&listQuery, "query", "q", "", "Filter by substring of the destination URL")
+	listCmd.Flags().StringVar(&listSort, "sort", "", "Sort by created_at (default) or click_count")
+	listCmd.Flags().StringVar(&listOrder, "order", "", "Sort order: desc (default) or asc")
+	listCmd.Flags().BoolVar(&listHasClicks, "has-clicks", false, "Only links with (true) or without (false) clicks")
+	listCmd.Flags().UintVar(&listOwner, "owner", 0, "Filter by owner API key ID (admin only)")
+	listCmd.Flags().StringVar(&listCreatedAfter, "created-after", "", "Only links created at or after this time (RFC 3339)")
+	listCmd.Flags().StringVar(&listCreatedBefore, "created-before", "", "Only links created before this time (RFC 3339)")
+}
@@ -4,27 +4,24 @@ import (
 	"fmt"
 
 	"github.com/fatih/color"
-	"github.com/lincyaw/tools/client/pkg/client"
 	"github.com/spf13/cobra"
 )
 
+var redirectPassword string
+
 var redirectCmd = &cobra.Command{
 	Use:   "redirect [short code]",
 	Short: "Test short link redirect",
-	Long:  `Test the redirect function of the specified short code, display the redirect status code and target URL.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Test the redirect function of the specified short code, display the redirect status code and target URL.
+Use --password for password protected links.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(_ *cobra.Command, args []string) {
 		code := args[0]
-		var c *client.Client
-		if insecureSkipVerify {
-			c = client.NewClientWithInsecureSkipVerify(baseURL)
-		} else {
-			c = client.NewClient(baseURL)
-		}
+		c := newClient()
 
 		color.Cyan("Testing redirect for short code '%s'...", code)
 
-		info, err := c.TestRedirect(code)
+		info, err := c.TestRedirectWithPassword(code, redirectPassword)
 		if err != nil {
 			color.Red("✗ Redirect test failed: %v", err)
 			return
@@ -41,4 +38,6 @@ var redirectCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(redirectCmd)
+
+	redirectCmd.Flags().StringVarP(&redirectPassword, "password", "p", "", "Password of a protected link")
 }
@@ -4,11 +4,16 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/lincyaw/tools/client/pkg/client"
 	"github.com/spf13/cobra"
 )
 
+// apiKeyEnv environment variable used when --api-key is not set
+const apiKeyEnv = "SHORTCODE_API_KEY"
+
 var (
 	baseURL            string
+	apiKey             string
 	verbose            bool
 	insecureSkipVerify bool
 )
@@ -20,9 +25,15 @@ var rootCmd = &cobra.Command{
 
 Supported operations:
   - Create short links (auto-generated or custom short codes)
-  - Get short link statistics
+  - Import short links in bulk from CSV or NDJSON
+  - List and search short links
+  - Update short link destinations
+  - Get short link statistics and follow clicks live
   - Delete short links
-  - Run complete test suite`,
+  - Manage API keys
+  - Run complete test suite
+
+Authenticate with --api-key or the SHORTCODE_API_KEY environment variable.`,
 }
 
 // Execute executes the root command
@@ -35,6 +46,23 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&baseURL, "url", "u", "http://localhost", "Service base URL")
+	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "a", "", "API key (defaults to $"+apiKeyEnv+")")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate verification (use only for testing)")
 }
+
+// newClient creates a service client from the global flags
+func newClient() *client.Client {
+	var c *client.Client
+	if insecureSkipVerify {
+		c = client.NewClientWithInsecureSkipVerify(baseURL)
+	} else {
+		c = client.NewClient(baseURL)
+	}
+
+	c.APIKey = apiKey
+	if c.APIKey == "" {
+		c.APIKey = os.Getenv(apiKeyEnv)
+	}
+	return c
+}
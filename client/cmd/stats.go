@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -10,23 +15,43 @@ import (
 )
 
 var (
-	detailedStats bool
-	statsHours    int
+	detailedStats    bool
+	statsHours       int
+	statsFrom        string
+	statsTo          string
+	statsGranularity string
+	statsTimezone    string
+	excludeInternal  bool
+	statsFollow      bool
 )
 
+// followReconnectDelay time to wait before reconnecting a dropped live stream
+const followReconnectDelay = 3 * time.Second
+
 var statsCmd = &cobra.Command{
 	Use:   "stats [short code]",
 	Short: "Get short link statistics",
-	Long:  `Get statistics for the specified short code, including click count, creation time, etc.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Get statistics for the specified short code, including click count, creation time, etc.
+
+With --follow, clicks are shown live as they are recorded, for the short code or, without one,
+for all short links of the API key.`,
+	Args: cobra.RangeArgs(0, 1),
 	Run: func(_ *cobra.Command, args []string) {
-		code := args[0]
-		var c *client.Client
-		if insecureSkipVerify {
-			c = client.NewClientWithInsecureSkipVerify(baseURL)
-		} else {
-			c = client.NewClient(baseURL)
+		c := newClient()
+
+		if statsFollow {
+			code := ""
+			if len(args) == 1 {
+				code = args[0]
+			}
+			followClicks(c, code)
+			return
+		}
+		if len(args) == 0 {
+			color.Red("✗ A short code is required unless --follow is given")
+			return
 		}
+		code := args[0]
 
 		if detailedStats {
 			// Get detailed statistics
@@ -35,7 +60,14 @@ var statsCmd = &cobra.Command{
 				color.Cyan("(Looking back %d hours)", statsHours)
 			}
 
-			stats, err := c.GetDetailedStats(code, statsHours)
+			stats, err := c.GetDetailedStats(code, client.DetailedStatsOptions{
+				Hours:           statsHours,
+				From:            statsFrom,
+				To:              statsTo,
+				Granularity:     statsGranularity,
+				Timezone:        statsTimezone,
+				ExcludeInternal: excludeInternal,
+			})
 			if err != nil {
 				color.Red("✗ Failed to get detailed statistics: %v", err)
 				return
@@ -52,6 +84,13 @@ var statsCmd = &cobra.Command{
 			fmt.Printf("Original URL:    %s\n", stats.OriginalURL)
 			fmt.Printf("Total clicks:    %d\n", stats.TotalClicks)
 			fmt.Printf("Unique IPs:      %d\n", stats.UniqueIPs)
+			fmt.Printf("Redirect type:   %d\n", stats.RedirectType)
+			fmt.Printf("Remaining uses:  %s\n", remainingLabel(stats.RemainingClicks))
+			fmt.Printf("Fallback hits:   %d\n", stats.FallbackCount)
+			fmt.Printf("Bot clicks:      %d\n", stats.BotClicks)
+			if excludeInternal {
+				fmt.Printf("Internal hits:   %d (excluded)\n", stats.InternalClicks)
+			}
 			fmt.Printf("Created at:      %s\n", stats.CreatedAt.Format(time.RFC3339))
 			if stats.LastAccessedAt != nil {
 				fmt.Printf("Last accessed:   %s\n", stats.LastAccessedAt.Format(time.RFC3339))
@@ -60,23 +99,23 @@ var statsCmd = &cobra.Command{
 			}
 			fmt.Println()
 
-			// Hourly statistics
-			if len(stats.HourlyStats) > 0 {
+			// Time series, the latest buckets
+			if len(stats.TimeSeries) > 0 {
+				series := stats.TimeSeries
+				if len(series) > 24 {
+					series = series[len(series)-24:]
+				}
 				color.Cyan("═══════════════════════════════════════════════")
-				color.Cyan("Hourly Statistics (Top 10)")
+				color.Cyan("Clicks per %s (%s, latest %d)", stats.Granularity, stats.Timezone, len(series))
 				color.Cyan("═══════════════════════════════════════════════")
-				fmt.Printf("%-20s %12s %12s\n", "Hour", "Accesses", "Unique IPs")
-				fmt.Println("-------------------------------------------------------")
-				limit := 10
-				if len(stats.HourlyStats) < limit {
-					limit = len(stats.HourlyStats)
-				}
-				for i := 0; i < limit; i++ {
-					h := stats.HourlyStats[i]
-					fmt.Printf("%-20s %12d %12d\n",
-						h.HourBucket.Format("2006-01-02 15:04"),
-						h.AccessCount,
-						h.UniqueIPs)
+				fmt.Printf("%-20s %12s %12s %8s\n", "Bucket", "Accesses", "Unique IPs", "Bots")
+				fmt.Println("---------------------------------------------------------------")
+				for _, item := range series {
+					fmt.Printf("%-20s %12d %12d %8d\n",
+						bucketLabel(item.Bucket, stats.Granularity),
+						item.AccessCount,
+						item.UniqueIPs,
+						item.BotCount)
 				}
 				fmt.Println()
 			}
@@ -111,6 +150,43 @@ var statsCmd = &cobra.Command{
 				fmt.Println()
 			}
 
+			// Browser, OS and device breakdowns
+			printBreakdown("Browsers", stats.BrowserStats)
+			printBreakdown("Operating Systems", stats.OSStats)
+			printBreakdown("Devices", stats.DeviceStats)
+
+			// Referrers and campaigns
+			printBreakdown("Referrer Types", stats.ReferrerGroups)
+			if len(stats.ReferrerStats) > 0 {
+				color.Cyan("═══════════════════════════════════════════════")
+				color.Cyan("Top Referrers (Top 10)")
+				color.Cyan("═══════════════════════════════════════════════")
+				fmt.Printf("%-30s %-10s %10s\n", "Referrer", "Type", "Accesses")
+				fmt.Println("----------------------------------------------------")
+				for i, r := range stats.ReferrerStats {
+					if i == 10 {
+						break
+					}
+					fmt.Printf("%-30s %-10s %10d\n", r.Source, r.Group, r.AccessCount)
+				}
+				fmt.Println()
+			}
+			if len(stats.CampaignStats) > 0 {
+				color.Cyan("═══════════════════════════════════════════════")
+				color.Cyan("UTM Campaigns (Top 10)")
+				color.Cyan("═══════════════════════════════════════════════")
+				fmt.Printf("%-20s %-15s %-25s %10s\n", "Source", "Medium", "Campaign", "Accesses")
+				fmt.Println("-------------------------------------------------------------------------")
+				for i, campaign := range stats.CampaignStats {
+					if i == 10 {
+						break
+					}
+					fmt.Printf("%-20s %-15s %-25s %10d\n",
+						orDash(campaign.Source), orDash(campaign.Medium), orDash(campaign.Campaign), campaign.AccessCount)
+				}
+				fmt.Println()
+			}
+
 			// Recent accesses
 			if len(stats.RecentAccesses) > 0 {
 				color.Cyan("═══════════════════════════════════════════════")
@@ -122,19 +198,22 @@ var statsCmd = &cobra.Command{
 				}
 				for i := 0; i < limit; i++ {
 					r := stats.RecentAccesses[i]
-					fmt.Printf("\n[%s]\n", r.AccessTime.Format("2006-01-02 15:04:05"))
+					if r.IsBot {
+						fmt.Printf("\n[%s] (bot)\n", r.AccessTime.Format("2006-01-02 15:04:05"))
+					} else {
+						fmt.Printf("\n[%s]\n", r.AccessTime.Format("2006-01-02 15:04:05"))
+					}
 					fmt.Printf("  IP:       %s\n", r.IPAddress)
 					location := fmt.Sprintf("%s, %s, %s", r.Country, r.Region, r.City)
 					if r.Country == "" {
 						location = "Unknown"
 					}
 					fmt.Printf("  Location: %s\n", location)
-					if r.UserAgent != "" {
-						userAgent := r.UserAgent
-						if len(userAgent) > 60 {
-							userAgent = userAgent[:60] + "..."
-						}
-						fmt.Printf("  UA:       %s\n", userAgent)
+					if agent := clientLabel(r); agent != "" {
+						fmt.Printf("  Client:   %s\n", agent)
+					}
+					if r.Referrer != "" {
+						fmt.Printf("  Referrer: %s\n", r.Referrer)
 					}
 				}
 				fmt.Println()
@@ -155,6 +234,12 @@ var statsCmd = &cobra.Command{
 			color.Cyan("Short code:      %s", stats.Code)
 			color.Cyan("Original URL:    %s", stats.OriginalURL)
 			color.Cyan("Click count:     %d", stats.ClickCount)
+			color.Cyan("Redirect type:   %d", stats.RedirectType)
+			color.Cyan("Password:        %s", protectedLabel(stats.PasswordProtected))
+			color.Cyan("Remaining uses:  %s", remainingLabel(stats.RemainingClicks))
+			color.Cyan("Fallback hits:   %d", stats.FallbackCount)
+			color.Cyan("Bot clicks:      %d", stats.BotClickCount)
+			color.Cyan("Disabled:        %t", stats.Disabled)
 			color.Cyan("Created at:      %s", stats.CreatedAt.Format(time.RFC3339))
 			if stats.LastAccessedAt != nil {
 				color.Cyan("Last accessed:   %s", stats.LastAccessedAt.Format(time.RFC3339))
@@ -166,8 +251,131 @@ var statsCmd = &cobra.Command{
 	},
 }
 
+// followClicks prints clicks as they arrive until interrupted, reconnecting when the stream drops
+func followClicks(c *client.Client, code string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if code != "" {
+		color.Cyan("Following clicks on short code '%s' (Ctrl+C to stop)...", code)
+	} else {
+		color.Cyan("Following clicks on all short links of the API key (Ctrl+C to stop)...")
+	}
+	fmt.Printf("%-8s  %-12s %-30s %-35s %s\n", "Time", "Code", "Location", "Client", "Referrer")
+
+	for {
+		err := c.FollowClicks(ctx, code, printLiveClick)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !errors.Is(err, client.ErrStreamInterrupted) {
+			color.Red("✗ Failed to follow clicks: %v", err)
+			return
+		}
+		color.Yellow("%v, reconnecting in %s...", err, followReconnectDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(followReconnectDelay):
+		}
+	}
+}
+
+// printLiveClick prints one line per click received from the live stream
+func printLiveClick(click client.LiveClick) {
+	location := click.Country
+	if location == "" {
+		location = "Unknown"
+	} else if click.City != "" {
+		location = click.City + ", " + click.Country
+	}
+
+	label := click.Browser
+	if label == "" {
+		label = "Unknown browser"
+	}
+	if click.OS != "" {
+		label += " on " + click.OS
+	}
+	label = fmt.Sprintf("%s (%s)", label, click.Device)
+	if click.Bot {
+		label += " bot"
+	}
+
+	fmt.Printf("%-8s  %-12s %-30s %-35s %s\n",
+		click.OccurredAt.Local().Format("15:04:05"), click.Code, location, label, click.ReferrerSource)
+}
+
+// printBreakdown prints the top 10 entries of a browser, OS, device or referrer type breakdown
+func printBreakdown(title string, items []client.BreakdownItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	color.Cyan("═══════════════════════════════════════════════")
+	color.Cyan("%s (Top 10)", title)
+	color.Cyan("═══════════════════════════════════════════════")
+	fmt.Printf("%-30s %10s\n", "Name", "Accesses")
+	fmt.Println("-----------------------------------------")
+	for i, item := range items {
+		if i == 10 {
+			break
+		}
+		fmt.Printf("%-30s %10d\n", item.Name, item.AccessCount)
+	}
+	fmt.Println()
+}
+
+// bucketLabel formats the start of a time series bucket as precisely as its granularity needs
+func bucketLabel(bucket time.Time, granularity string) string {
+	switch granularity {
+	case "day", "week":
+		return bucket.Format("2006-01-02")
+	case "month":
+		return bucket.Format("2006-01")
+	default:
+		return bucket.Format("2006-01-02 15:04")
+	}
+}
+
+// orDash shows empty UTM parameters as "-"
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// clientLabel describes the browser, OS and device of an access, e.g. "Chrome on Android (mobile)".
+// Falls back to the truncated raw user agent for clicks recorded before user agents were parsed.
+func clientLabel(r client.RecentAccessItem) string {
+	if r.Device == "" {
+		if len(r.UserAgent) > 60 {
+			return r.UserAgent[:60] + "..."
+		}
+		return r.UserAgent
+	}
+
+	label := r.Browser
+	if label == "" {
+		label = "Unknown browser"
+	}
+	if r.OS != "" {
+		label += " on " + r.OS
+	}
+	return fmt.Sprintf("%s (%s)", label, r.Device)
+}
+
 func init() {
 	rootCmd.AddCommand(statsCmd)
 	statsCmd.Flags().BoolVarP(&detailedStats, "detailed", "d", false, "Show detailed statistics including hourly data and location info")
 	statsCmd.Flags().IntVarP(&statsHours, "hours", "H", 0, "Number of hours to look back (0 = all time)")
+	statsCmd.Flags().StringVar(&statsFrom, "from", "", "Start of the range, RFC 3339 or YYYY-MM-DD (with --detailed)")
+	statsCmd.Flags().StringVar(&statsTo, "to", "", "End of the range, RFC 3339 or YYYY-MM-DD, the whole day included (with --detailed)")
+	statsCmd.Flags().StringVarP(&statsGranularity, "granularity", "g", "", "Time series buckets: minute, hour, day, week or month (with --detailed)")
+	statsCmd.Flags().StringVar(&statsTimezone, "tz", "", "IANA time zone of the buckets and dates, e.g. Europe/Berlin (default UTC)")
+	statsCmd.Flags().BoolVarP(&statsFollow, "follow", "f", false, "Stream clicks live as they are recorded (all links of the API key without a short code)")
+	statsCmd.Flags().BoolVarP(&excludeInternal, "exclude-internal", "x", false, "Leave out clicks from private, loopback and reserved IP addresses (with --detailed)")
 }
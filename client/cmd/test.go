@@ -9,16 +9,19 @@ import (
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Run complete test suite",
-	Long:  `Run the complete test suite, testing all API endpoint functions.`,
+	Long: `Run the complete test suite, testing all API endpoint functions.
+
+Deleting links and reading detailed statistics require an API key.`,
 	Run: func(_ *cobra.Command, _ []string) {
+		key := newClient().APIKey
 		var tester *test.Tester
 		if insecureSkipVerify {
-			tester = test.NewTesterWithInsecureSkipVerify(baseURL, verbose)
+			tester = test.NewTesterWithInsecureSkipVerify(baseURL, key, verbose)
 			if verbose {
 				color.Yellow("⚠ Warning: Skipping TLS certificate verification")
 			}
 		} else {
-			tester = test.NewTester(baseURL, verbose)
+			tester = test.NewTester(baseURL, key, verbose)
 		}
 		tester.RunAllTests()
 	},
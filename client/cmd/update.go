@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lincyaw/tools/client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateURL          string
+	updateExpiresIn    int
+	updateExpiresAt    string
+	updateActivatesAt  string
+	updateRedirectType int
+	updatePassword     string
+	updateMaxClicks    int64
+	updateFallbackURL  string
+	updateDisabled     bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update [short code]",
+	Short: "Update short link",
+	Long: `Update the destination, schedule, redirect type, password, click limit, fallback URL or
+disabled state of an existing short link, the short code stays the same.
+
+Only the flags that are given are changed. Use --expires 0 to remove the expiration, --expires-at
+for an absolute expiration instead of --expires, --activates-at to move the activation time
+(--activates-at "" removes it), --redirect-type 0 to go back to the service default, --password ""
+to remove the password, --max-clicks 0 to remove the click limit and --fallback-url "" to remove
+the fallback URL.
+Disabled links redirect to their fallback URL, re-enable them with --disabled=false.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		code := args[0]
+		c := newClient()
+
+		var req client.UpdateShortCodeRequest
+		if cmd.Flags().Changed("long-url") {
+			req.URL = &updateURL
+		}
+		if cmd.Flags().Changed("expires") {
+			req.ExpiresIn = &updateExpiresIn
+		}
+		expiry, err := parseTimeFlag(updateExpiresAt)
+		if err != nil {
+			color.Red("✗ Invalid --expires-at: %v", err)
+			return
+		}
+		req.ExpiresAt = expiry
+		activation, err := parseTimeFlag(updateActivatesAt)
+		if err != nil {
+			color.Red("✗ Invalid --activates-at: %v", err)
+			return
+		}
+		if activation == nil && cmd.Flags().Changed("activates-at") {
+			activation = &time.Time{}
+		}
+		req.ActivatesAt = activation
+		if cmd.Flags().Changed("redirect-type") {
+			req.RedirectType = &updateRedirectType
+		}
+		if cmd.Flags().Changed("password") {
+			req.Password = &updatePassword
+		}
+		if cmd.Flags().Changed("max-clicks") {
+			req.MaxClicks = &updateMaxClicks
+		}
+		if cmd.Flags().Changed("fallback-url") {
+			req.FallbackURL = &updateFallbackURL
+		}
+		if cmd.Flags().Changed("disabled") {
+			req.Disabled = &updateDisabled
+		}
+
+		if req == (client.UpdateShortCodeRequest{}) {
+			color.Red("✗ Nothing to update, see --help for the available flags")
+			return
+		}
+
+		color.Cyan("Updating short code '%s'...", code)
+
+		info, err := c.UpdateShortCode(code, req)
+		if err != nil {
+			color.Red("✗ Update failed: %v", err)
+			return
+		}
+
+		color.Green("\n✓ Short link updated successfully!")
+		fmt.Println()
+		color.Cyan("Short code:      %s", info.Code)
+		color.Cyan("Short link:      %s", info.ShortURL)
+		color.Cyan("Original URL:    %s", info.OriginalURL)
+		color.Cyan("Redirect type:   %d", info.RedirectType)
+		color.Cyan("Password:        %s", protectedLabel(info.PasswordProtected))
+		color.Cyan("Remaining uses:  %s", remainingLabel(info.RemainingClicks))
+		color.Cyan("Disabled:        %t", info.Disabled)
+		if info.FallbackURL != "" {
+			color.Cyan("Fallback URL:    %s", info.FallbackURL)
+		}
+		color.Cyan("Updated at:      %s", info.UpdatedAt.Format(time.RFC3339))
+		if info.ActivatesAt != nil {
+			color.Cyan("Activates at:    %s", info.ActivatesAt.Format(time.RFC3339))
+		}
+		if info.ExpiresAt != nil {
+			color.Cyan("Expires at:      %s", info.ExpiresAt.Format(time.RFC3339))
+		} else {
+			color.Cyan("Expires at:      Never")
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringVarP(&updateURL, "long-url", "l", "", "New destination URL")
+	updateCmd.Flags().IntVarP(&updateExpiresIn, "expires", "e", 0, "New expiration time (hours from now, 0 = never)")
+	updateCmd.Flags().StringVar(&updateExpiresAt, "expires-at", "", "New absolute expiration time, RFC 3339 (instead of --expires)")
+	updateCmd.Flags().StringVar(&updateActivatesAt, "activates-at", "", "New time the link starts redirecting, RFC 3339 (empty string removes it)")
+	updateCmd.Flags().IntVarP(&updateRedirectType, "redirect-type", "r", 0, "New redirect status code: 301, 302, 307 or 308 (0 = service default)")
+	updateCmd.Flags().StringVarP(&updatePassword, "password", "p", "", "New password (empty string removes the password)")
+	updateCmd.Flags().Int64VarP(&updateMaxClicks, "max-clicks", "m", 0, "New click limit (0 = unlimited)")
+	updateCmd.Flags().StringVar(&updateFallbackURL, "fallback-url", "", "New fallback URL (empty string removes it)")
+	updateCmd.Flags().BoolVar(&updateDisabled, "disabled", false, "Disable the link, --disabled=false enables it again")
+}
+
+// protectedLabel describes whether a link is password protected
+func protectedLabel(protected bool) string {
+	if protected {
+		return "Protected"
+	}
+	return "None"
+}
+
+// remainingLabel describes the remaining uses of a click-limited link
+func remainingLabel(remaining *int64) string {
+	if remaining == nil {
+		return "Unlimited"
+	}
+	return strconv.FormatInt(*remaining, 10)
+}
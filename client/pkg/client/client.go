@@ -7,11 +7,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// redirectUserAgent browser-like user agent of test redirects, so they are counted as human clicks
+const redirectUserAgent = "Mozilla/5.0 (X11; Linux x86_64) shortcode-client/1.0"
+
 type Client struct {
 	BaseURL    string
+	APIKey     string // Optional API key sent as a bearer token
 	HTTPClient *http.Client
 }
 
@@ -49,46 +55,187 @@ func NewClientWithInsecureSkipVerify(baseURL string) *Client {
 
 // CreateShortCodeRequest create short link request
 type CreateShortCodeRequest struct {
-	URL        string `json:"url"`
-	CustomCode string `json:"custom_code,omitempty"`
-	ExpiresIn  int    `json:"expires_in,omitempty"`
+	URL          string     `json:"url"`
+	CustomCode   string     `json:"custom_code,omitempty"`
+	ExpiresIn    int        `json:"expires_in,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // Absolute expiration time, instead of ExpiresIn
+	ActivatesAt  *time.Time `json:"activates_at,omitempty"`  // Redirects start at this time
+	RedirectType int        `json:"redirect_type,omitempty"` // 301, 302, 307 or 308, 0 uses the service default
+	Password     string     `json:"password,omitempty"`      // Protect the link with a password (4-72 characters)
+	MaxClicks    int64      `json:"max_clicks,omitempty"`    // Redirects allowed before the link is exhausted, 1 for one-time links
+	FallbackURL  string     `json:"fallback_url,omitempty"`  // Destination once the link is expired, exhausted or disabled
+}
+
+// BatchCreateRequest bulk create short links request
+type BatchCreateRequest struct {
+	Items   []CreateShortCodeRequest `json:"items"`
+	Partial bool                     `json:"partial,omitempty"` // Create the valid items even if others fail
+}
+
+// BatchCreateResult result of one item of a bulk create request
+type BatchCreateResult struct {
+	Index   int                      `json:"index"`
+	Success bool                     `json:"success"`
+	Result  *CreateShortCodeResponse `json:"result,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+	Message string                   `json:"message,omitempty"`
+}
+
+// BatchCreateResponse bulk create short links response
+type BatchCreateResponse struct {
+	Results   []BatchCreateResult `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+// UpdateShortCodeRequest update short link request, nil fields are left unchanged
+type UpdateShortCodeRequest struct {
+	URL          *string    `json:"url,omitempty"`
+	ExpiresIn    *int       `json:"expires_in,omitempty"`    // Hours from now, 0 removes the expiration
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // Absolute expiration time, instead of ExpiresIn
+	ActivatesAt  *time.Time `json:"activates_at,omitempty"`  // Redirects start at this time, a past time activates the link now, the zero time removes it
+	RedirectType *int       `json:"redirect_type,omitempty"` // 301, 302, 307 or 308, 0 resets to the service default
+	Password     *string    `json:"password,omitempty"`      // New password, empty string removes the password
+	MaxClicks    *int64     `json:"max_clicks,omitempty"`    // New click limit, 0 removes the limit
+	FallbackURL  *string    `json:"fallback_url,omitempty"`  // New fallback URL, empty string removes it
+	Disabled     *bool      `json:"disabled,omitempty"`      // Disabled links redirect to the fallback URL
 }
 
 // CreateShortCodeResponse create short link response
 type CreateShortCodeResponse struct {
-	ShortCode   string     `json:"short_code"`
-	ShortURL    string     `json:"short_url"`
-	OriginalURL string     `json:"original_url"`
-	CreatedAt   time.Time  `json:"created_at"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ShortCode         string     `json:"short_code"`
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt       *time.Time `json:"activates_at,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	FallbackURL       string     `json:"fallback_url,omitempty"`
+}
+
+// ShortCodeInfo short link details
+type ShortCodeInfo struct {
+	Code              string     `json:"code"`
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt       *time.Time `json:"activates_at,omitempty"`
+	ClickCount        int64      `json:"click_count"`
+	LastAccessedAt    *time.Time `json:"last_accessed_at,omitempty"`
+	OwnerID           *uint      `json:"owner_id,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	RemainingClicks   *int64     `json:"remaining_clicks,omitempty"`
+	FallbackURL       string     `json:"fallback_url,omitempty"`
+	FallbackCount     int64      `json:"fallback_count"`
+	Disabled          bool       `json:"disabled"`
+}
+
+// ListOptions filters and pagination for listing short links, zero values are omitted
+type ListOptions struct {
+	Cursor        string
+	Limit         int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Status        string // active or expired
+	HasClicks     *bool
+	OwnerID       *uint // Admin keys only
+	Query         string
+	Sort          string // created_at or click_count
+	Order         string // asc or desc
+}
+
+// ListShortCodesResponse list short links response
+type ListShortCodesResponse struct {
+	Items      []ShortCodeInfo `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
 }
 
 // ShortCodeStats short link statistics
 type ShortCodeStats struct {
-	Code           string     `json:"code"`
-	OriginalURL    string     `json:"original_url"`
-	ClickCount     int64      `json:"click_count"`
-	CreatedAt      time.Time  `json:"created_at"`
-	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	Code              string     `json:"code"`
+	OriginalURL       string     `json:"original_url"`
+	ClickCount        int64      `json:"click_count"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastAccessedAt    *time.Time `json:"last_accessed_at,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	RemainingClicks   *int64     `json:"remaining_clicks,omitempty"`
+	FallbackCount     int64      `json:"fallback_count"`
+	Disabled          bool       `json:"disabled"`
+	BotClickCount     int64      `json:"bot_click_count"`
+}
+
+// DetailedStatsOptions filters of detailed statistics, zero values are omitted
+type DetailedStatsOptions struct {
+	Hours           int    // Look back window, 0 = all time, ignored if From is set
+	From            string // Start of the range, RFC 3339 timestamp or YYYY-MM-DD date in Timezone
+	To              string // End of the range, a date includes the whole day, default now
+	Granularity     string // Time series buckets: minute, hour (server default), day, week or month
+	Timezone        string // IANA time zone of the buckets and dates, server default UTC
+	ExcludeInternal bool   // Leave out private, loopback and reserved IP addresses
 }
 
 // DetailedStats detailed statistics response
 type DetailedStats struct {
-	Code           string             `json:"code"`
-	OriginalURL    string             `json:"original_url"`
-	TotalClicks    int64              `json:"total_clicks"`
-	UniqueIPs      int64              `json:"unique_ips"`
-	CreatedAt      time.Time          `json:"created_at"`
-	LastAccessedAt *time.Time         `json:"last_accessed_at,omitempty"`
-	HourlyStats    []HourlyStatItem   `json:"hourly_stats"`
-	LocationStats  []LocationStatItem `json:"location_stats"`
-	RecentAccesses []RecentAccessItem `json:"recent_accesses"`
-}
-
-// HourlyStatItem hourly statistics item
-type HourlyStatItem struct {
-	HourBucket  time.Time `json:"hour_bucket"`
+	Code            string             `json:"code"`
+	OriginalURL     string             `json:"original_url"`
+	TotalClicks     int64              `json:"total_clicks"`
+	UniqueIPs       int64              `json:"unique_ips"`
+	CreatedAt       time.Time          `json:"created_at"`
+	LastAccessedAt  *time.Time         `json:"last_accessed_at,omitempty"`
+	RedirectType    int                `json:"redirect_type"`
+	MaxClicks       *int64             `json:"max_clicks,omitempty"`
+	RemainingClicks *int64             `json:"remaining_clicks,omitempty"`
+	FallbackCount   int64              `json:"fallback_count"`
+	BotClicks       int64              `json:"bot_clicks"`
+	InternalClicks  int64              `json:"internal_clicks,omitempty"`
+	Granularity     string             `json:"granularity"`
+	Timezone        string             `json:"timezone"`
+	TimeSeries      []TimeSeriesItem   `json:"time_series"`
+	LocationStats   []LocationStatItem `json:"location_stats"`
+	BrowserStats    []BreakdownItem    `json:"browser_stats"`
+	OSStats         []BreakdownItem    `json:"os_stats"`
+	DeviceStats     []BreakdownItem    `json:"device_stats"`
+	ReferrerGroups  []BreakdownItem    `json:"referrer_groups"`
+	ReferrerStats   []ReferrerStatItem `json:"referrer_stats"`
+	CampaignStats   []CampaignStatItem `json:"campaign_stats"`
+	RecentAccesses  []RecentAccessItem `json:"recent_accesses"`
+}
+
+// ReferrerStatItem clicks from one referring site
+type ReferrerStatItem struct {
+	Source      string `json:"source"`
+	Group       string `json:"group"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// CampaignStatItem clicks of one UTM source, medium and campaign combination
+type CampaignStatItem struct {
+	Source      string `json:"utm_source"`
+	Medium      string `json:"utm_medium"`
+	Campaign    string `json:"utm_campaign"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// BreakdownItem clicks of one browser, operating system or device class
+type BreakdownItem struct {
+	Name        string `json:"name"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// TimeSeriesItem clicks in one time series bucket
+type TimeSeriesItem struct {
+	Bucket      time.Time `json:"bucket"`
 	AccessCount int64     `json:"access_count"`
+	BotCount    int64     `json:"bot_count"`
 	UniqueIPs   int64     `json:"unique_ips"`
 }
 
@@ -108,6 +255,11 @@ type RecentAccessItem struct {
 	City       string    `json:"city"`
 	AccessTime time.Time `json:"access_time"`
 	UserAgent  string    `json:"user_agent"`
+	IsBot      bool      `json:"is_bot"`
+	Referrer   string    `json:"referrer"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	Device     string    `json:"device"`
 }
 
 // ErrorResponse error response
@@ -116,6 +268,22 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// CreateAPIKeyRequest create API key request
+type CreateAPIKeyRequest struct {
+	Name    string `json:"name"`
+	IsAdmin bool   `json:"is_admin,omitempty"`
+}
+
+// CreateAPIKeyResponse create API key response
+type CreateAPIKeyResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Key       string    `json:"key"`
+	KeyPrefix string    `json:"key_prefix"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // RedirectInfo redirect information
 type RedirectInfo struct {
 	StatusCode  int
@@ -130,11 +298,12 @@ func (c *Client) CreateShortCode(req CreateShortCodeRequest) (*CreateShortCodeRe
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Post(
-		c.BaseURL+"/api/v1/shorten",
-		"application/json",
-		bytes.NewBuffer(data),
-	)
+	httpReq, err := c.newRequest(http.MethodPost, "/api/v1/shorten", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -146,11 +315,7 @@ func (c *Client) CreateShortCode(req CreateShortCodeRequest) (*CreateShortCodeRe
 	}
 
 	if resp.StatusCode != http.StatusCreated {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-		}
-		return nil, fmt.Errorf("API error (%d): %s - %s", resp.StatusCode, errResp.Error, errResp.Message)
+		return nil, parseError(resp.StatusCode, body)
 	}
 
 	var result CreateShortCodeResponse
@@ -161,9 +326,122 @@ func (c *Client) CreateShortCode(req CreateShortCodeRequest) (*CreateShortCodeRe
 	return &result, nil
 }
 
+// CreateShortCodesBatch create many short links in one request.
+// Per-item failures are reported in the response, an error is only returned if the request itself failed.
+func (c *Client) CreateShortCodesBatch(req BatchCreateRequest) (*BatchCreateResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(http.MethodPost, "/api/v1/shorten/batch", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusMultiStatus, http.StatusBadRequest:
+		var result BatchCreateResponse
+		if err := json.Unmarshal(body, &result); err == nil && result.Results != nil {
+			return &result, nil
+		}
+	}
+
+	return nil, parseError(resp.StatusCode, body)
+}
+
+// UpdateShortCode update the destination or expiration of a short link
+func (c *Client) UpdateShortCode(code string, req UpdateShortCodeRequest) (*ShortCodeInfo, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(http.MethodPatch, "/api/v1/shorten/"+code, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	var result ShortCodeInfo
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ListShortCodes get one page of short links
+func (c *Client) ListShortCodes(opts ListOptions) (*ListShortCodesResponse, error) {
+	req, err := c.newRequest(http.MethodGet, "/api/v1/shorten?"+opts.values().Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	var result ListShortCodesResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// IterateShortCodes returns an iterator that walks all pages of short links transparently
+func (c *Client) IterateShortCodes(opts ListOptions) *ShortCodeIterator {
+	return &ShortCodeIterator{
+		client: c,
+		opts:   opts,
+	}
+}
+
 // GetStats get short link statistics
 func (c *Client) GetStats(code string) (*ShortCodeStats, error) {
-	resp, err := c.HTTPClient.Get(c.BaseURL + "/api/v1/stats/" + code)
+	req, err := c.newRequest(http.MethodGet, "/api/v1/stats/"+code, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -175,11 +453,7 @@ func (c *Client) GetStats(code string) (*ShortCodeStats, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-		}
-		return nil, fmt.Errorf("API error (%d): %s - %s", resp.StatusCode, errResp.Error, errResp.Message)
+		return nil, parseError(resp.StatusCode, body)
 	}
 
 	var stats ShortCodeStats
@@ -190,14 +464,72 @@ func (c *Client) GetStats(code string) (*ShortCodeStats, error) {
 	return &stats, nil
 }
 
+// ExportOptions options of a statistics export
+type ExportOptions struct {
+	DetailedStatsOptions        // Range, time zone and filters; Granularity applies to time series
+	Format               string // csv (server default) or ndjson
+}
+
+// ExportClicks streams the raw click logs of a short link to w, returns the bytes written
+func (c *Client) ExportClicks(code string, opts ExportOptions, w io.Writer) (int64, error) {
+	return c.export(fmt.Sprintf("/api/v1/stats/%s/export/clicks", code), opts, w)
+}
+
+// ExportTimeSeries streams the click time series of a short link to w, returns the bytes written
+func (c *Client) ExportTimeSeries(code string, opts ExportOptions, w io.Writer) (int64, error) {
+	return c.export(fmt.Sprintf("/api/v1/stats/%s/export/timeseries", code), opts, w)
+}
+
+// export streams an export response to w
+func (c *Client) export(path string, opts ExportOptions, w io.Writer) (int64, error) {
+	values := opts.values()
+	if opts.Format != "" {
+		values.Set("format", opts.Format)
+	}
+	if query := values.Encode(); query != "" {
+		path += "?" + query
+	}
+
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+
+	// Exports can take longer than the request timeout, the server keeps them flowing
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = 0
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, parseError(resp.StatusCode, body)
+	}
+
+	written, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return written, fmt.Errorf("read export: %w", err)
+	}
+	return written, nil
+}
+
 // GetDetailedStats get detailed short link statistics
-func (c *Client) GetDetailedStats(code string, hours int) (*DetailedStats, error) {
-	url := fmt.Sprintf("%s/api/v1/stats/%s/detailed", c.BaseURL, code)
-	if hours > 0 {
-		url = fmt.Sprintf("%s?hours=%d", url, hours)
+func (c *Client) GetDetailedStats(code string, opts DetailedStatsOptions) (*DetailedStats, error) {
+	path := fmt.Sprintf("/api/v1/stats/%s/detailed", code)
+	if query := opts.values().Encode(); query != "" {
+		path += "?" + query
 	}
 
-	resp, err := c.HTTPClient.Get(url)
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -209,11 +541,7 @@ func (c *Client) GetDetailedStats(code string, hours int) (*DetailedStats, error
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-		}
-		return nil, fmt.Errorf("API error (%d): %s - %s", resp.StatusCode, errResp.Error, errResp.Message)
+		return nil, parseError(resp.StatusCode, body)
 	}
 
 	var stats DetailedStats
@@ -226,7 +554,32 @@ func (c *Client) GetDetailedStats(code string, hours int) (*DetailedStats, error
 
 // TestRedirect test short link redirect
 func (c *Client) TestRedirect(code string) (*RedirectInfo, error) {
-	resp, err := c.HTTPClient.Get(c.BaseURL + "/" + code)
+	return c.TestRedirectWithPassword(code, "")
+}
+
+// TestRedirectWithPassword test redirect of a password protected short link
+func (c *Client) TestRedirectWithPassword(code, password string) (*RedirectInfo, error) {
+	headers := map[string]string{}
+	if password != "" {
+		headers["X-Link-Password"] = password
+	}
+	return c.TestRedirectWithHeaders(code, headers)
+}
+
+// TestRedirectWithHeaders test redirect with additional request headers. The redirect looks like a
+// browser click unless the headers override the User-Agent, HTTP library user agents are counted as bots.
+func (c *Client) TestRedirectWithHeaders(code string, headers map[string]string) (*RedirectInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/"+code, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", redirectUserAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -251,7 +604,7 @@ func (c *Client) TestRedirect(code string) (*RedirectInfo, error) {
 
 // DeleteShortCode delete short link
 func (c *Client) DeleteShortCode(code string) error {
-	req, err := http.NewRequest("DELETE", c.BaseURL+"/api/v1/shorten/"+code, nil)
+	req, err := c.newRequest(http.MethodDelete, "/api/v1/shorten/"+code, nil)
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
@@ -264,11 +617,63 @@ func (c *Client) DeleteShortCode(code string) error {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-		}
-		return fmt.Errorf("API error (%d): %s - %s", resp.StatusCode, errResp.Error, errResp.Message)
+		return parseError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// CreateAPIKey create API key, requires an admin key
+func (c *Client) CreateAPIKey(req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(http.MethodPost, "/api/v1/keys", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	var result CreateAPIKeyResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// RevokeAPIKey revoke API key, requires an admin key
+func (c *Client) RevokeAPIKey(id uint) error {
+	req, err := c.newRequest(http.MethodDelete, fmt.Sprintf("/api/v1/keys/%d", id), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return parseError(resp.StatusCode, body)
 	}
 
 	return nil
@@ -288,3 +693,89 @@ func (c *Client) HealthCheck() error {
 
 	return nil
 }
+
+// newRequest creates a request against the service, carrying the API key if configured
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	return req, nil
+}
+
+// parseError converts an error response into an error
+func parseError(statusCode int, body []byte) error {
+	var errResp ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return fmt.Errorf("unexpected status %d: %s", statusCode, string(body))
+	}
+	return fmt.Errorf("API error (%d): %s - %s", statusCode, errResp.Error, errResp.Message)
+}
+
+// values encodes the options as query parameters
+func (o ListOptions) values() url.Values {
+	values := url.Values{}
+	if o.Cursor != "" {
+		values.Set("cursor", o.Cursor)
+	}
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.CreatedAfter != nil {
+		values.Set("created_after", o.CreatedAfter.Format(time.RFC3339))
+	}
+	if o.CreatedBefore != nil {
+		values.Set("created_before", o.CreatedBefore.Format(time.RFC3339))
+	}
+	if o.Status != "" {
+		values.Set("status", o.Status)
+	}
+	if o.HasClicks != nil {
+		values.Set("has_clicks", strconv.FormatBool(*o.HasClicks))
+	}
+	if o.OwnerID != nil {
+		values.Set("owner_id", strconv.FormatUint(uint64(*o.OwnerID), 10))
+	}
+	if o.Query != "" {
+		values.Set("q", o.Query)
+	}
+	if o.Sort != "" {
+		values.Set("sort", o.Sort)
+	}
+	if o.Order != "" {
+		values.Set("order", o.Order)
+	}
+	return values
+}
+
+// values encodes the options as query parameters
+func (o DetailedStatsOptions) values() url.Values {
+	values := url.Values{}
+	if o.Hours > 0 {
+		values.Set("hours", strconv.Itoa(o.Hours))
+	}
+	if o.From != "" {
+		values.Set("from", o.From)
+	}
+	if o.To != "" {
+		values.Set("to", o.To)
+	}
+	if o.Granularity != "" {
+		values.Set("granularity", o.Granularity)
+	}
+	if o.Timezone != "" {
+		values.Set("tz", o.Timezone)
+	}
+	if o.ExcludeInternal {
+		values.Set("exclude_internal", "true")
+	}
+	return values
+}
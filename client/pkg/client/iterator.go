@@ -0,0 +1,54 @@
+package client
+
+// ShortCodeIterator walks all pages of a short link listing, fetching pages on demand
+//
+//	it := c.IterateShortCodes(client.ListOptions{Status: "active"})
+//	for it.Next() {
+//		fmt.Println(it.Item().Code)
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+type ShortCodeIterator struct {
+	client  *Client
+	opts    ListOptions
+	page    []ShortCodeInfo
+	pos     int
+	current ShortCodeInfo
+	done    bool
+	err     error
+}
+
+// Next advances to the next short link, returns false when all pages are consumed or an error occurred
+func (it *ShortCodeIterator) Next() bool {
+	for it.pos >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		resp, err := it.client.ListShortCodes(it.opts)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = resp.Items
+		it.pos = 0
+		it.opts.Cursor = resp.NextCursor
+		it.done = !resp.HasMore || resp.NextCursor == ""
+	}
+
+	it.current = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Item returns the current short link
+func (it *ShortCodeIterator) Item() ShortCodeInfo {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ShortCodeIterator) Err() error {
+	return it.err
+}
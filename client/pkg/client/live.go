@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrStreamInterrupted the live stream ended after it was established, e.g. the server restarted; following it again resumes
+var ErrStreamInterrupted = errors.New("live stream interrupted")
+
+// LiveClick click received from the live click stream, with its location and parsed user agent
+type LiveClick struct {
+	Code           string    `json:"code"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	Bot            bool      `json:"bot"`
+	IPClass        string    `json:"ip_class"`
+	Country        string    `json:"country"`
+	Region         string    `json:"region"`
+	City           string    `json:"city"`
+	Browser        string    `json:"browser"`
+	OS             string    `json:"os"`
+	Device         string    `json:"device"`
+	ReferrerSource string    `json:"referrer_source"`
+	ReferrerGroup  string    `json:"referrer_group"`
+	UTMSource      string    `json:"utm_source"`
+	UTMMedium      string    `json:"utm_medium"`
+	UTMCampaign    string    `json:"utm_campaign"`
+}
+
+// FollowClicks streams the clicks of a short link as they are recorded, or of all links of the API key
+// for an empty code, calling fn for each click. It returns when the context is canceled (nil error) or
+// the stream is interrupted (ErrStreamInterrupted).
+func (c *Client) FollowClicks(ctx context.Context, code string, fn func(LiveClick)) error {
+	path := "/api/v1/clicks/live"
+	if code != "" {
+		path = fmt.Sprintf("/api/v1/stats/%s/live", code)
+	}
+
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream stays open until it is stopped, the server sends heartbeats while idle
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = 0
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return parseError(resp.StatusCode, body)
+	}
+
+	// Server-Sent Events: "field:value" lines, an empty line ends an event, ":" lines are comments
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "click" && data != "" {
+				var click LiveClick
+				if err := json.Unmarshal([]byte(data), &click); err != nil {
+					return fmt.Errorf("parse click: %w", err)
+				}
+				fn(click)
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrStreamInterrupted, err)
+	}
+	return fmt.Errorf("%w: closed by the server", ErrStreamInterrupted)
+}
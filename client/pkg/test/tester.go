@@ -1,7 +1,10 @@
 package test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -23,19 +26,23 @@ type Tester struct {
 	verbose bool
 }
 
-// NewTester create tester
-func NewTester(baseURL string, verbose bool) *Tester {
+// NewTester create tester, the API key owns the short links created during the tests
+func NewTester(baseURL, apiKey string, verbose bool) *Tester {
+	c := client.NewClient(baseURL)
+	c.APIKey = apiKey
 	return &Tester{
-		client:  client.NewClient(baseURL),
+		client:  c,
 		results: make([]Result, 0),
 		verbose: verbose,
 	}
 }
 
 // NewTesterWithInsecureSkipVerify creates a tester that skips TLS certificate verification
-func NewTesterWithInsecureSkipVerify(baseURL string, verbose bool) *Tester {
+func NewTesterWithInsecureSkipVerify(baseURL, apiKey string, verbose bool) *Tester {
+	c := client.NewClientWithInsecureSkipVerify(baseURL)
+	c.APIKey = apiKey
 	return &Tester{
-		client:  client.NewClientWithInsecureSkipVerify(baseURL),
+		client:  c,
 		results: make([]Result, 0),
 		verbose: verbose,
 	}
@@ -145,6 +152,15 @@ func (t *Tester) TestDuplicateCustomCode(customCode string) {
 	}
 }
 
+// seriesTotal sums the human accesses of a time series
+func seriesTotal(series []client.TimeSeriesItem) int64 {
+	var total int64
+	for _, item := range series {
+		total += item.AccessCount
+	}
+	return total
+}
+
 // TestRedirect test redirect
 func (t *Tester) TestRedirect(code string) {
 	color.Cyan("\n━━━ Test Short Link Redirect ━━━")
@@ -192,7 +208,7 @@ func (t *Tester) TestGetDetailedStats(code string) {
 	color.Cyan("\n━━━ Test Get Detailed Statistics ━━━")
 
 	// Test without time range (all time)
-	stats, err := t.client.GetDetailedStats(code, 0)
+	stats, err := t.client.GetDetailedStats(code, client.DetailedStatsOptions{})
 	if err != nil {
 		t.addResult("Get Detailed Statistics (All Time)", false, "Failed to get", err)
 		return
@@ -207,12 +223,13 @@ func (t *Tester) TestGetDetailedStats(code string) {
 		color.Yellow("  Unique IPs: %d", stats.UniqueIPs)
 		color.Yellow("  Created at: %s", stats.CreatedAt.Format(time.RFC3339))
 
-		if len(stats.HourlyStats) > 0 {
-			color.Yellow("  Hourly stats entries: %d", len(stats.HourlyStats))
-			color.Yellow("  Latest hour bucket: %s (%d accesses, %d unique IPs)",
-				stats.HourlyStats[0].HourBucket.Format("2006-01-02 15:04"),
-				stats.HourlyStats[0].AccessCount,
-				stats.HourlyStats[0].UniqueIPs)
+		if len(stats.TimeSeries) > 0 {
+			latest := stats.TimeSeries[len(stats.TimeSeries)-1]
+			color.Yellow("  Time series buckets: %d per %s", len(stats.TimeSeries), stats.Granularity)
+			color.Yellow("  Latest bucket: %s (%d accesses, %d unique IPs)",
+				latest.Bucket.Format("2006-01-02 15:04"),
+				latest.AccessCount,
+				latest.UniqueIPs)
 		}
 
 		if len(stats.LocationStats) > 0 {
@@ -232,7 +249,7 @@ func (t *Tester) TestGetDetailedStats(code string) {
 	}
 
 	// Test with time range (last 24 hours)
-	stats24h, err := t.client.GetDetailedStats(code, 24)
+	stats24h, err := t.client.GetDetailedStats(code, client.DetailedStatsOptions{Hours: 24})
 	if err != nil {
 		t.addResult("Get Detailed Statistics (24h)", false, "Failed to get", err)
 		return
@@ -244,8 +261,43 @@ func (t *Tester) TestGetDetailedStats(code string) {
 	if t.verbose {
 		color.Yellow("  Last 24h total clicks: %d", stats24h.TotalClicks)
 		color.Yellow("  Last 24h unique IPs: %d", stats24h.UniqueIPs)
-		color.Yellow("  Last 24h hourly entries: %d", len(stats24h.HourlyStats))
+		color.Yellow("  Last 24h hourly buckets: %d", len(stats24h.TimeSeries))
+	}
+}
+
+// TestExport checks that clicks and the time series can be exported as CSV and NDJSON
+func (t *Tester) TestExport(code string) {
+	color.Cyan("\n━━━ Test Export ━━━")
+
+	var clicks bytes.Buffer
+	_, err := t.client.ExportClicks(code, client.ExportOptions{Format: "csv"}, &clicks)
+	if err != nil {
+		t.addResult("Export Clicks (CSV)", false, "Failed to export", err)
+	} else if lines := strings.Split(strings.TrimSpace(clicks.String()), "\n"); !strings.HasPrefix(lines[0], "time,ip_address,") {
+		t.addResult("Export Clicks (CSV)", false, fmt.Sprintf("Unexpected header: %s", lines[0]), nil)
+	} else {
+		t.addResult("Export Clicks (CSV)", true, fmt.Sprintf("Exported %d click(s)", len(lines)-1), nil)
 	}
+
+	var series bytes.Buffer
+	_, err = t.client.ExportTimeSeries(code, client.ExportOptions{
+		DetailedStatsOptions: client.DetailedStatsOptions{Granularity: "day"},
+		Format:               "ndjson",
+	}, &series)
+	if err != nil {
+		t.addResult("Export Time Series (NDJSON)", false, "Failed to export", err)
+		return
+	}
+	var buckets int
+	for _, line := range strings.Split(strings.TrimSpace(series.String()), "\n") {
+		var item client.TimeSeriesItem
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.addResult("Export Time Series (NDJSON)", false, "Invalid NDJSON line", err)
+			return
+		}
+		buckets++
+	}
+	t.addResult("Export Time Series (NDJSON)", true, fmt.Sprintf("Exported %d daily bucket(s)", buckets), nil)
 }
 
 // TestAccessStatisticsRecording test that access statistics are properly recorded
@@ -266,7 +318,7 @@ func (t *Tester) TestAccessStatisticsRecording() {
 	}
 
 	// Get initial stats (should be 0)
-	initialStats, err := t.client.GetDetailedStats(testCode, 0)
+	initialStats, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{})
 	if err != nil {
 		t.addResult("Statistics Test - Get Initial Stats", false, "Failed to get initial stats", err)
 		return
@@ -297,7 +349,7 @@ func (t *Tester) TestAccessStatisticsRecording() {
 	time.Sleep(3 * time.Second)
 
 	// Get updated stats
-	updatedStats, err := t.client.GetDetailedStats(testCode, 0)
+	updatedStats, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{})
 	if err != nil {
 		t.addResult("Statistics Test - Get Updated Stats", false, "Failed to get updated stats", err)
 		return
@@ -312,18 +364,35 @@ func (t *Tester) TestAccessStatisticsRecording() {
 		t.addResult("Statistics Test - Click Recording", false, msg, nil)
 	}
 
-	// Verify hourly stats exist
-	if len(updatedStats.HourlyStats) > 0 {
+	// Verify the hourly time series holds the clicks
+	if hourly := seriesTotal(updatedStats.TimeSeries); hourly > 0 {
 		t.addResult("Statistics Test - Hourly Stats", true,
-			fmt.Sprintf("Hourly statistics created (%d entries)", len(updatedStats.HourlyStats)), nil)
+			fmt.Sprintf("Hourly time series holds %d accesses in %d buckets", hourly, len(updatedStats.TimeSeries)), nil)
 
 		if t.verbose {
-			for i, h := range updatedStats.HourlyStats {
+			for i, h := range updatedStats.TimeSeries {
 				color.Yellow("    Hour %d: %s - %d accesses, %d unique IPs",
-					i+1, h.HourBucket.Format("2006-01-02 15:04"),
+					i+1, h.Bucket.Format("2006-01-02 15:04"),
 					h.AccessCount, h.UniqueIPs)
 			}
 		}
+
+		// Same clicks in daily buckets of a time zone that is not a whole number of hours from UTC
+		daily, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{
+			Granularity: "day",
+			Timezone:    "Asia/Kolkata",
+		})
+		switch {
+		case err != nil:
+			t.addResult("Statistics Test - Daily Stats", false, "Failed to get daily statistics", err)
+		case daily.Granularity != "day" || daily.Timezone != "Asia/Kolkata" || seriesTotal(daily.TimeSeries) != hourly:
+			t.addResult("Statistics Test - Daily Stats", false,
+				fmt.Sprintf("Expected %d accesses per day in Asia/Kolkata, got %d per %s in %s",
+					hourly, seriesTotal(daily.TimeSeries), daily.Granularity, daily.Timezone), nil)
+		default:
+			t.addResult("Statistics Test - Daily Stats", true,
+				fmt.Sprintf("Daily time series in Asia/Kolkata holds the same %d accesses", hourly), nil)
+		}
 	} else {
 		t.addResult("Statistics Test - Hourly Stats", false, "No hourly statistics created", nil)
 	}
@@ -373,7 +442,7 @@ func (t *Tester) TestAccessStatisticsRecording() {
 	}
 
 	// Test time range filtering (last 1 hour)
-	stats1h, err := t.client.GetDetailedStats(testCode, 1)
+	stats1h, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{Hours: 1})
 	if err != nil {
 		t.addResult("Statistics Test - Time Range Filter", false, "Failed to get 1h stats", err)
 	} else {
@@ -448,6 +517,103 @@ func (t *Tester) TestDeleteShortCode() {
 	}
 }
 
+// TestUpdateShortCode test changing the destination of a short link
+func (t *Tester) TestUpdateShortCode() {
+	color.Cyan("\n━━━ Test Update Short Link ━━━")
+
+	if t.client.APIKey == "" {
+		t.addResult("Update Short Link", true, "Skipped (no API key configured)", nil)
+		return
+	}
+
+	updateCode := fmt.Sprintf("update%d", time.Now().Unix())
+	req := client.CreateShortCodeRequest{
+		URL:        "https://example.com/before",
+		CustomCode: updateCode,
+	}
+
+	if _, err := t.client.CreateShortCode(req); err != nil {
+		t.addResult("Update Test - Create Code", false, "Failed to create test code", err)
+		return
+	}
+
+	// Populate the cache so the update has to invalidate it
+	_, _ = t.client.TestRedirect(updateCode)
+
+	newURL := "https://example.com/after"
+	expiresIn := 0
+	info, err := t.client.UpdateShortCode(updateCode, client.UpdateShortCodeRequest{
+		URL:       &newURL,
+		ExpiresIn: &expiresIn,
+	})
+	if err != nil {
+		t.addResult("Update Short Link", false, "Update failed", err)
+		return
+	}
+	t.addResult("Update Short Link", true, fmt.Sprintf("Destination changed to %s", info.OriginalURL), nil)
+
+	redirect, err := t.client.TestRedirect(updateCode)
+	switch {
+	case err != nil:
+		t.addResult("Update Verification", false, "Redirect failed after update", err)
+	case redirect.Location != newURL:
+		t.addResult("Update Verification", false, fmt.Sprintf("Redirect still points to %s", redirect.Location), nil)
+	default:
+		t.addResult("Update Verification", true, "Redirect uses the new destination", nil)
+	}
+
+	if err := t.client.DeleteShortCode(updateCode); err != nil && t.verbose {
+		color.Yellow("  Warning: Failed to cleanup test code: %v", err)
+	}
+}
+
+// TestOwnership test that only the owner can manage a short link
+func (t *Tester) TestOwnership() {
+	color.Cyan("\n━━━ Test Short Link Ownership ━━━")
+
+	if t.client.APIKey == "" {
+		t.addResult("Ownership", true, "Skipped (no API key configured)", nil)
+		return
+	}
+
+	ownedCode := fmt.Sprintf("owned%d", time.Now().Unix())
+	req := client.CreateShortCodeRequest{
+		URL:        "https://example.com",
+		CustomCode: ownedCode,
+	}
+
+	if _, err := t.client.CreateShortCode(req); err != nil {
+		t.addResult("Ownership - Create Owned Code", false, "Failed to create owned short code", err)
+		return
+	}
+
+	// Anonymous callers must not be able to delete or inspect the link
+	anonymous := *t.client
+	anonymous.APIKey = ""
+
+	if err := anonymous.DeleteShortCode(ownedCode); err != nil {
+		t.addResult("Ownership - Anonymous Delete", true, "Correctly rejected anonymous deletion", nil)
+		if t.verbose {
+			color.Yellow("  Error message: %v", err)
+		}
+	} else {
+		t.addResult("Ownership - Anonymous Delete", false, "Anonymous caller deleted an owned short code", nil)
+		return
+	}
+
+	if _, err := anonymous.GetDetailedStats(ownedCode, client.DetailedStatsOptions{}); err != nil {
+		t.addResult("Ownership - Anonymous Detailed Stats", true, "Correctly rejected anonymous access", nil)
+	} else {
+		t.addResult("Ownership - Anonymous Detailed Stats", false, "Anonymous caller read detailed statistics", nil)
+	}
+
+	if err := t.client.DeleteShortCode(ownedCode); err != nil {
+		t.addResult("Ownership - Owner Delete", false, "Owner failed to delete short code", err)
+	} else {
+		t.addResult("Ownership - Owner Delete", true, "Owner deleted short code", nil)
+	}
+}
+
 // TestRateLimiting test rate limiting
 func (t *Tester) TestRateLimiting() {
 	color.Cyan("\n━━━ Test Rate Limiting ━━━")
@@ -483,6 +649,156 @@ func (t *Tester) TestRateLimiting() {
 	}
 }
 
+// TestClientIPSpoofing checks that client IP headers sent by the client itself are not trusted
+func (t *Tester) TestClientIPSpoofing() {
+	color.Cyan("\n━━━ Test Client IP Spoofing ━━━")
+
+	// TEST-NET-3 address, never the real address of the tester
+	const spoofedIP = "203.0.113.77"
+
+	testCode := fmt.Sprintf("spoof%d", time.Now().Unix())
+	_, err := t.client.CreateShortCode(client.CreateShortCodeRequest{
+		URL:        "https://example.com/spoof-test",
+		CustomCode: testCode,
+	})
+	if err != nil {
+		t.addResult("IP Spoofing - Create Code", false, "Failed to create test code", err)
+		return
+	}
+	defer func() {
+		_ = t.client.DeleteShortCode(testCode)
+	}()
+
+	_, err = t.client.TestRedirectWithHeaders(testCode, map[string]string{
+		"X-Forwarded-For": spoofedIP,
+		"X-Real-IP":       spoofedIP,
+		"X-Client-IP":     spoofedIP,
+	})
+	if err != nil {
+		t.addResult("IP Spoofing - Redirect", false, "Redirect failed", err)
+		return
+	}
+
+	// Wait for async processing
+	time.Sleep(3 * time.Second)
+
+	stats, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{})
+	if err != nil {
+		t.addResult("IP Spoofing - Get Stats", false, "Failed to get stats", err)
+		return
+	}
+	if len(stats.RecentAccesses) == 0 {
+		t.addResult("IP Spoofing", false, "Click was not recorded", nil)
+		return
+	}
+
+	for _, access := range stats.RecentAccesses {
+		if access.IPAddress == spoofedIP {
+			t.addResult("IP Spoofing", false,
+				"Spoofed client IP was recorded, check TRUSTED_PROXIES and that the service is only reachable through the proxy", nil)
+			return
+		}
+	}
+	t.addResult("IP Spoofing", true, fmt.Sprintf("Spoofed headers ignored, recorded IP: %s", stats.RecentAccesses[0].IPAddress), nil)
+}
+
+// TestBotClicks checks that link unfurlers neither use up one-time links nor count as clicks
+func (t *Tester) TestBotClicks() {
+	color.Cyan("\n━━━ Test Bot Clicks ━━━")
+
+	testCode := fmt.Sprintf("bot%d", time.Now().Unix())
+	_, err := t.client.CreateShortCode(client.CreateShortCodeRequest{
+		URL:        "https://example.com/bot-test",
+		CustomCode: testCode,
+		MaxClicks:  1,
+	})
+	if err != nil {
+		t.addResult("Bot Clicks - Create Code", false, "Failed to create test code", err)
+		return
+	}
+	defer func() {
+		_ = t.client.DeleteShortCode(testCode)
+	}()
+
+	// Unfurl the link the way a chat app does before anyone clicks it
+	_, err = t.client.TestRedirectWithHeaders(testCode, map[string]string{
+		"User-Agent": "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+	})
+	if err != nil {
+		t.addResult("Bot Clicks - Unfurl", false, "Unfurl redirect failed", err)
+		return
+	}
+
+	info, err := t.client.TestRedirect(testCode)
+	if err != nil || info.Location != "https://example.com/bot-test" {
+		t.addResult("Bot Clicks - One-Time Link", false, "Unfurling used up the one-time link", err)
+		return
+	}
+	t.addResult("Bot Clicks - One-Time Link", true, "One-time link still works after unfurling", nil)
+
+	// Wait for async processing
+	time.Sleep(3 * time.Second)
+
+	stats, err := t.client.GetStats(testCode)
+	if err != nil {
+		t.addResult("Bot Clicks - Get Stats", false, "Failed to get stats", err)
+		return
+	}
+	if stats.ClickCount != 1 || stats.BotClickCount != 1 {
+		t.addResult("Bot Clicks - Counts", false,
+			fmt.Sprintf("Expected 1 click and 1 bot click, got %d and %d", stats.ClickCount, stats.BotClickCount), nil)
+		return
+	}
+	t.addResult("Bot Clicks - Counts", true, "Human and bot clicks counted separately", nil)
+}
+
+// TestReferrerStats checks that referrers are normalized and UTM parameters extracted
+func (t *Tester) TestReferrerStats() {
+	color.Cyan("\n━━━ Test Referrer Statistics ━━━")
+
+	testCode := fmt.Sprintf("ref%d", time.Now().Unix())
+	_, err := t.client.CreateShortCode(client.CreateShortCodeRequest{
+		URL:        "https://example.com/referrer-test",
+		CustomCode: testCode,
+	})
+	if err != nil {
+		t.addResult("Referrer Stats - Create Code", false, "Failed to create test code", err)
+		return
+	}
+	defer func() {
+		_ = t.client.DeleteShortCode(testCode)
+	}()
+
+	_, err = t.client.TestRedirectWithHeaders(testCode, map[string]string{
+		"Referer": "https://www.news.example.com/post?utm_source=newsletter&utm_medium=email&utm_campaign=spring",
+	})
+	if err != nil {
+		t.addResult("Referrer Stats - Redirect", false, "Redirect failed", err)
+		return
+	}
+
+	// Wait for async processing
+	time.Sleep(3 * time.Second)
+
+	stats, err := t.client.GetDetailedStats(testCode, client.DetailedStatsOptions{})
+	if err != nil {
+		t.addResult("Referrer Stats - Get Stats", false, "Failed to get stats", err)
+		return
+	}
+
+	if len(stats.ReferrerStats) == 0 || stats.ReferrerStats[0].Source != "news.example.com" {
+		t.addResult("Referrer Stats - Referrer", false, fmt.Sprintf("Unexpected referrers: %+v", stats.ReferrerStats), nil)
+	} else {
+		t.addResult("Referrer Stats - Referrer", true, "Referrer normalized to news.example.com", nil)
+	}
+
+	if len(stats.CampaignStats) == 0 || stats.CampaignStats[0].Campaign != "spring" {
+		t.addResult("Referrer Stats - Campaign", false, fmt.Sprintf("Unexpected campaigns: %+v", stats.CampaignStats), nil)
+	} else {
+		t.addResult("Referrer Stats - Campaign", true, "UTM campaign extracted from the referrer", nil)
+	}
+}
+
 // RunAllTests run all tests
 func (t *Tester) RunAllTests() {
 	color.Cyan("\n╔══════════════════════════════════════════════╗")
@@ -500,11 +816,17 @@ func (t *Tester) RunAllTests() {
 		t.TestRedirect(autoCode)
 		t.TestGetStats(autoCode)
 		t.TestGetDetailedStats(autoCode)
+		t.TestExport(autoCode)
 	}
 
 	t.TestInvalidRequests()
 	t.TestAccessStatisticsRecording()
 	t.TestDeleteShortCode()
+	t.TestUpdateShortCode()
+	t.TestOwnership()
+	t.TestClientIPSpoofing()
+	t.TestBotClicks()
+	t.TestReferrerStats()
 	t.TestRateLimiting()
 
 	t.PrintSummary()
@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,34 +12,55 @@ import (
 
 	"github.com/lincyaw/tools/services/shortcode/internal/api"
 	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/geo"
+	"github.com/lincyaw/tools/services/shortcode/internal/logging"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
 	"github.com/lincyaw/tools/services/shortcode/internal/repository"
 	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/lincyaw/tools/services/shortcode/internal/telemetry"
+	"github.com/lincyaw/tools/services/shortcode/internal/useragent"
+	"github.com/lincyaw/tools/services/shortcode/internal/worker"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	logging.Setup(cfg.Log)
+
+	// Initialize tracing, spans still buffered are flushed on exit
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), cfg.Tracing, "shortcode")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
 
 	// Initialize database
 	db, err := repository.NewPostgresDB(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 
 	sqlDB, _ := db.DB()
 	defer func() {
 		if err := sqlDB.Close(); err != nil {
-			log.Printf("Error closing database connection: %v", err)
+			slog.Error("Error closing database connection", "error", err)
 		}
 	}()
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established")
+	metrics.RegisterDBStats(sqlDB)
 
 	// Initialize Redis
 	redisClient := repository.NewRedisClient(cfg.Redis)
 	defer func() {
 		if err := redisClient.Close(); err != nil {
-			log.Printf("Error closing Redis connection: %v", err)
+			slog.Error("Error closing Redis connection", "error", err)
 		}
 	}()
 
@@ -46,19 +68,83 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Printf("Warning: Redis connection failed: %v", err)
+		slog.Warn("Redis connection failed", "error", err)
 	} else {
-		log.Println("Redis connection established")
+		slog.Info("Redis connection established")
 	}
 
 	// Initialize repository layer
 	repo := repository.NewShortCodeRepository(db, redisClient)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	liveRepo := repository.NewLiveClickRepository(redisClient)
+	webhookRepo := repository.NewWebhookRepository(db)
+
+	// Initialize IP geolocation
+	geoLocator, geoCloser, err := geo.New(cfg.Geo)
+	if err != nil {
+		logging.Fatal("Failed to initialize GeoIP provider", "error", err)
+	}
+	defer func() {
+		if err := geoCloser.Close(); err != nil {
+			slog.Error("Error closing GeoIP provider", "error", err)
+		}
+	}()
 
 	// Initialize service layer
-	svc := service.NewShortCodeService(repo, cfg.BaseURL)
+	webhookSvc := service.NewWebhookService(webhookRepo, cfg.Webhooks)
+	webhookBatcher := worker.NewWebhookBatcher(webhookSvc, cfg.Webhooks)
+	svc := service.NewShortCodeService(repo, liveRepo, geoLocator, webhookBatcher, cfg.BaseURL, cfg.Links)
+	apiKeySvc := service.NewAPIKeyService(apiKeyRepo)
+
+	// Register bootstrap admin key
+	if cfg.Auth.AdminAPIKey != "" {
+		err := apiKeySvc.EnsureAdminKey(ctx, cfg.Auth.AdminAPIKey)
+		switch {
+		case errors.Is(err, service.ErrAdminKeyRevoked):
+			slog.Warn("ADMIN_API_KEY was revoked and stays revoked, configure a new key to bootstrap an admin")
+		case err != nil:
+			logging.Fatal("Failed to register admin API key", "error", err)
+		default:
+			slog.Info("Admin API key registered")
+		}
+	}
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	sweeper := worker.NewExpirySweeper(repo, svc, cfg.Sweeper)
+	go sweeper.Run(workerCtx)
+
+	webhookDispatcher := worker.NewWebhookDispatcher(webhookRepo, cfg.Webhooks)
+	go webhookDispatcher.Run(workerCtx)
+
+	// Click pipeline, either an in-process queue or a durable Redis Stream
+	var clicks service.ClickSink
+	var clickQueue *worker.ClickQueue
+	if cfg.Clicks.Transport == "redis_stream" {
+		clicks = worker.NewClickStream(redisClient, cfg.Clicks)
+		if cfg.Clicks.ConsumeInProcess {
+			consumer := worker.NewClickConsumer(redisClient, svc, repo, cfg.Clicks)
+			go consumer.Run(workerCtx)
+		}
+	} else {
+		clickQueue = worker.NewClickQueue(svc, cfg.Clicks)
+		clicks = clickQueue
+	}
+	metrics.RegisterClickPipeline(func() (uint64, uint64, uint64, uint64, int) {
+		stats := clicks.Stats()
+		return stats.Enqueued, stats.Dropped, stats.Processed, stats.Failed, stats.Pending
+	})
+
+	// Bot and link unfurler detection
+	bots, err := useragent.LoadBotDetector(cfg.Bots)
+	if err != nil {
+		logging.Fatal("Failed to load bot signatures", "error", err)
+	}
 
 	// Initialize HTTP server
-	router := api.NewRouter(svc)
+	router := api.NewRouter(svc, apiKeySvc, webhookSvc, clicks, bots, cfg)
 
 	srv := &http.Server{
 		Addr:           ":" + cfg.Port,
@@ -67,12 +153,18 @@ func main() {
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1 MB
 	}
+	// Live click streams never finish on their own, they are ended when shutdown starts
+	srv.RegisterOnShutdown(func() {
+		if err := liveRepo.Close(); err != nil {
+			slog.Error("Error closing live click stream", "error", err)
+		}
+	})
 
 	// Start server
 	go func() {
-		log.Printf("Starting server on port %s (environment: %s)", cfg.Port, cfg.Environment)
+		slog.Info("Starting server", "port", cfg.Port, "env", cfg.Environment)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			logging.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
@@ -81,16 +173,33 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
+	stopWorkers()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		shutdownCancel()
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
 	shutdownCancel()
 
-	log.Println("Server exited gracefully")
+	// Write the clicks still queued before the database connection is closed
+	if clickQueue != nil {
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if err := clickQueue.Close(drainCtx); err != nil {
+			slog.Error("Click queue not fully drained", "error", err, "pending", clickQueue.Stats().Pending)
+		}
+		drainCancel()
+	}
+
+	// Queue the webhook deliveries of the last link events and clicks, they are sent after a restart
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := webhookBatcher.Close(drainCtx); err != nil {
+		slog.Error("Webhook events not fully queued", "error", err)
+	}
+	drainCancel()
+
+	slog.Info("Server exited gracefully")
 }
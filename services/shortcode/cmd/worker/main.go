@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/geo"
+	"github.com/lincyaw/tools/services/shortcode/internal/logging"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/lincyaw/tools/services/shortcode/internal/telemetry"
+	"github.com/lincyaw/tools/services/shortcode/internal/worker"
+)
+
+// Standalone click consumer, records click events published to the Redis Stream by the server
+// (CLICK_TRANSPORT=redis_stream). Several workers can run side by side in the same consumer group.
+func main() {
+	// Load configuration
+	cfg := config.Load()
+	logging.Setup(cfg.Log)
+
+	// Initialize tracing, spans still buffered are flushed on exit
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), cfg.Tracing, "shortcode-worker")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	// Initialize database
+	db, err := repository.NewPostgresDB(cfg.Database)
+	if err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+
+	sqlDB, _ := db.DB()
+	defer func() {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("Error closing database connection", "error", err)
+		}
+	}()
+	metrics.RegisterDBStats(sqlDB)
+
+	// Initialize Redis
+	redisClient := repository.NewRedisClient(cfg.Redis)
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			slog.Error("Error closing Redis connection", "error", err)
+		}
+	}()
+
+	pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = redisClient.Ping(pingCtx).Err()
+	pingCancel()
+	if err != nil {
+		logging.Fatal("Failed to connect to Redis", "error", err)
+	}
+
+	repo := repository.NewShortCodeRepository(db, redisClient)
+	// Initialize IP geolocation
+	geoLocator, geoCloser, err := geo.New(cfg.Geo)
+	if err != nil {
+		logging.Fatal("Failed to initialize GeoIP provider", "error", err)
+	}
+	defer func() {
+		if err := geoCloser.Close(); err != nil {
+			slog.Error("Error closing GeoIP provider", "error", err)
+		}
+	}()
+
+	// Clicks recorded here are reported to webhooks and live streams too, the server sends the deliveries
+	webhookSvc := service.NewWebhookService(repository.NewWebhookRepository(db), cfg.Webhooks)
+	webhookBatcher := worker.NewWebhookBatcher(webhookSvc, cfg.Webhooks)
+	svc := service.NewShortCodeService(repo, repository.NewLiveClickRepository(redisClient), geoLocator, webhookBatcher, cfg.BaseURL, cfg.Links)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Geo lookups and the database pool of the worker are only visible on its own metrics port
+	if cfg.Clicks.WorkerMetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		srv := &http.Server{Addr: ":" + cfg.Clicks.WorkerMetricsPort, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Metrics server stopped", "error", err)
+			}
+		}()
+		defer func() { _ = srv.Close() }()
+	}
+
+	consumer := worker.NewClickConsumer(redisClient, svc, repo, cfg.Clicks)
+	consumer.Run(ctx)
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := webhookBatcher.Close(drainCtx); err != nil {
+		slog.Error("Webhook events not fully queued", "error", err)
+	}
+	drainCancel()
+
+	slog.Info("Worker exited")
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// CreateAPIKey create API key
+// @Summary Create API key
+// @Description Create a new API key, requires an admin key. The plain key is only returned once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body model.CreateAPIKeyRequest true "Create API key request"
+// @Success 201 {object} model.CreateAPIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req model.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.apiKeys.CreateAPIKey(c.Request.Context(), &req, currentAPIKey(c))
+	if err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeAPIKey revoke API key
+// @Summary Revoke API key
+// @Description Revoke an API key, requires an admin key
+// @Tags auth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The API key ID must be a positive integer",
+		})
+		return
+	}
+
+	if err := h.apiKeys.RevokeAPIKey(c.Request.Context(), uint(id), currentAPIKey(c)); err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "API key not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+	})
+}
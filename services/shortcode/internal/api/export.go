@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// exportWriteTimeout time the client gets to take each flushed part of an export, the server
+// write timeout would otherwise cut long exports off
+const exportWriteTimeout = 30 * time.Second
+
+// Export formats
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+)
+
+// clickExportColumns CSV header of click exports, in the order of clickRecord
+var clickExportColumns = []string{
+	"time", "ip_address", "ip_class", "country", "region", "city", "is_bot",
+	"user_agent", "browser", "browser_version", "os", "os_version", "device",
+	"referer", "referrer_source", "referrer_group", "utm_source", "utm_medium", "utm_campaign",
+}
+
+// timeSeriesExportColumns CSV header of time series exports, in the order of timeSeriesRecord
+var timeSeriesExportColumns = []string{"bucket", "access_count", "bot_count", "unique_ips"}
+
+// exportWriter writes export rows as CSV records or NDJSON lines and flushes them to the client
+type exportWriter struct {
+	c       *gin.Context
+	format  string
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+// newExportWriter creates export writer, the response starts with the first write
+func newExportWriter(c *gin.Context, format string) *exportWriter {
+	return &exportWriter{
+		c:      c,
+		format: format,
+		csv:    csv.NewWriter(c.Writer),
+		json:   json.NewEncoder(c.Writer),
+	}
+}
+
+// start writes the response headers and the CSV header row
+func (w *exportWriter) start(filename string, columns []string) error {
+	w.started = true
+
+	header := w.c.Writer.Header()
+	if w.format == exportCSV {
+		header.Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		header.Set("Content-Type", "application/x-ndjson")
+	}
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, w.format))
+	w.c.Status(http.StatusOK)
+
+	if w.format == exportCSV {
+		return w.csv.Write(columns)
+	}
+	return nil
+}
+
+// write writes one row, record for CSV and value for NDJSON
+func (w *exportWriter) write(record []string, value interface{}) error {
+	if w.format == exportCSV {
+		for i, field := range record {
+			record[i] = escapeFormula(field)
+		}
+		return w.csv.Write(record)
+	}
+	return w.json.Encode(value)
+}
+
+// escapeFormula keeps spreadsheets from running user agents or referrers as formulas
+func escapeFormula(field string) string {
+	if field != "" && strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+		return "'" + field
+	}
+	return field
+}
+
+// flush sends the buffered rows and gives the client another write timeout for the next part
+func (w *exportWriter) flush() error {
+	if w.format == exportCSV {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	w.c.Writer.Flush()
+
+	return extendWriteDeadline(w.c, exportWriteTimeout)
+}
+
+// extendWriteDeadline gives a streaming response another d to be written, past the server write timeout
+func extendWriteDeadline(c *gin.Context, d time.Duration) error {
+	err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(d))
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil // Not served by net/http, e.g. in tests, there is no write timeout to extend
+	}
+	return err
+}
+
+// ExportClicks export click logs
+// @Summary Export click logs
+// @Description Stream the raw click logs of a short link as CSV or NDJSON, oldest first
+// @Tags shortcode
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param code path string true "Short code"
+// @Param format query string false "csv (default) or ndjson"
+// @Param from query string false "Start of the range, RFC 3339 or YYYY-MM-DD in tz"
+// @Param to query string false "End of the range, RFC 3339 or YYYY-MM-DD in tz (whole day included), default now"
+// @Param tz query string false "IANA time zone of dates and exported times (default: UTC)"
+// @Param exclude_internal query bool false "Leave out private, loopback and reserved IP addresses"
+// @Success 200 {string} string "Click logs"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/stats/{code}/export/clicks [get]
+func (h *Handler) ExportClicks(c *gin.Context) {
+	code := c.Param("code")
+
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+	query, ok := parseStatsQuery(c)
+	if !ok {
+		return
+	}
+
+	// The response starts with the first batch, errors before it get a regular error response
+	w := newExportWriter(c, format)
+	err := h.service.ExportClicks(c.Request.Context(), code, query, currentAPIKey(c), func(rows []model.ClickExportRow) error {
+		if !w.started {
+			if err := w.start(code+"-clicks", clickExportColumns); err != nil {
+				return err
+			}
+		}
+		for i := range rows {
+			row := &rows[i]
+			row.Time = row.Time.In(query.Location)
+			if err := w.write(clickRecord(row), row); err != nil {
+				return err
+			}
+		}
+		return w.flush()
+	})
+	switch {
+	case err != nil && !w.started:
+		respondStatsError(c, err)
+		return
+	case err != nil:
+		slog.WarnContext(c.Request.Context(), "Click export stopped", "error", err) // Too late for an error response, the file ends early
+		return
+	case !w.started:
+		if err := w.start(code+"-clicks", clickExportColumns); err != nil {
+			return
+		}
+	}
+	_ = w.flush()
+}
+
+// ExportTimeSeries export time series
+// @Summary Export time series
+// @Description Stream the gap-filled click time series of a short link as CSV or NDJSON, oldest first
+// @Tags shortcode
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param code path string true "Short code"
+// @Param format query string false "csv (default) or ndjson"
+// @Param granularity query string false "minute, hour (default), day, week or month"
+// @Param from query string false "Start of the range, RFC 3339 or YYYY-MM-DD in tz"
+// @Param to query string false "End of the range, RFC 3339 or YYYY-MM-DD in tz (whole day included), default now"
+// @Param tz query string false "IANA time zone the buckets are aligned to (default: UTC)"
+// @Param exclude_internal query bool false "Leave out private, loopback and reserved IP addresses"
+// @Success 200 {string} string "Time series"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/stats/{code}/export/timeseries [get]
+func (h *Handler) ExportTimeSeries(c *gin.Context) {
+	code := c.Param("code")
+
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+	query, ok := parseStatsQuery(c)
+	if !ok {
+		return
+	}
+
+	// The series is bounded by the bucket limit, it is built before the response starts
+	series, err := h.service.ExportTimeSeries(c.Request.Context(), code, query, currentAPIKey(c))
+	if err != nil {
+		respondStatsError(c, err)
+		return
+	}
+
+	w := newExportWriter(c, format)
+	if err := w.start(code+"-timeseries", timeSeriesExportColumns); err != nil {
+		return
+	}
+	for i := range series {
+		if err := w.write(timeSeriesRecord(&series[i]), &series[i]); err != nil {
+			slog.WarnContext(c.Request.Context(), "Time series export stopped", "error", err)
+			return
+		}
+	}
+	_ = w.flush()
+}
+
+// parseExportFormat reads the export format, reports false after responding 400 to an unknown one
+func parseExportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", exportCSV)
+	if format != exportCSV && format != exportNDJSON {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be csv or ndjson",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// clickRecord CSV record of an exported click
+func clickRecord(row *model.ClickExportRow) []string {
+	return []string{
+		row.Time.Format(time.RFC3339), row.IPAddress, row.IPClass, row.Country, row.Region, row.City,
+		strconv.FormatBool(row.IsBot), row.UserAgent, row.Browser, row.BrowserVersion, row.OS,
+		row.OSVersion, row.Device, row.Referer, row.ReferrerSource, row.ReferrerGroup,
+		row.UTMSource, row.UTMMedium, row.UTMCampaign,
+	}
+}
+
+// timeSeriesRecord CSV record of an exported time series bucket
+func timeSeriesRecord(item *model.TimeSeriesItem) []string {
+	return []string{
+		item.Bucket.Format(time.RFC3339),
+		strconv.FormatInt(item.AccessCount, 10),
+		strconv.FormatInt(item.BotCount, 10),
+		strconv.FormatInt(item.UniqueIPs, 10),
+	}
+}
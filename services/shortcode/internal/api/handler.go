@@ -1,23 +1,42 @@
 package api
 
 import (
-	"context"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
 	"github.com/lincyaw/tools/services/shortcode/internal/model"
 	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/lincyaw/tools/services/shortcode/internal/telemetry"
+	"github.com/lincyaw/tools/services/shortcode/internal/useragent"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer creates the handler spans, the request span itself is started by the tracing middleware
+var tracer = otel.Tracer("github.com/lincyaw/tools/services/shortcode/internal/api")
+
 type Handler struct {
-	service service.ShortCodeService
+	service          service.ShortCodeService
+	apiKeys          service.APIKeyService
+	webhooks         service.WebhookService
+	clicks           service.ClickSink
+	bots             *useragent.BotDetector
+	passwordAttempts *RateLimiter // Failed password attempts per IP
 }
 
-func NewHandler(service service.ShortCodeService) *Handler {
+func NewHandler(service service.ShortCodeService, apiKeys service.APIKeyService, webhooks service.WebhookService, clicks service.ClickSink, bots *useragent.BotDetector, links config.LinkConfig) *Handler {
 	return &Handler{
-		service: service,
+		service:          service,
+		apiKeys:          apiKeys,
+		webhooks:         webhooks,
+		clicks:           clicks,
+		bots:             bots,
+		passwordAttempts: NewRateLimiter(links.PasswordMaxAttempts, links.PasswordLockoutWindow),
 	}
 }
 
@@ -35,6 +54,7 @@ type ErrorResponse struct {
 // @Param request body model.CreateShortCodeRequest true "Create short link request"
 // @Success 201 {object} model.CreateShortCodeResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/shorten [post]
@@ -48,7 +68,7 @@ func (h *Handler) CreateShortCode(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.CreateShortCode(c.Request.Context(), &req)
+	resp, err := h.service.CreateShortCode(c.Request.Context(), &req, currentAPIKey(c))
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrInvalidURL):
@@ -66,6 +86,31 @@ func (h *Handler) CreateShortCode(c *gin.Context) {
 				Error:   "invalid_code",
 				Message: "The code format is invalid (4-50 alphanumeric characters)",
 			})
+		case errors.Is(err, service.ErrInvalidRedirectType):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_redirect_type",
+				Message: "The redirect type must be 301, 302, 307 or 308",
+			})
+		case errors.Is(err, service.ErrInvalidPassword):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_password",
+				Message: "The password must be 4-72 characters",
+			})
+		case errors.Is(err, service.ErrInvalidMaxClicks):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_max_clicks",
+				Message: "The click limit must be a positive number",
+			})
+		case errors.Is(err, service.ErrInvalidFallbackURL):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_fallback_url",
+				Message: "The fallback URL is not valid",
+			})
+		case errors.Is(err, service.ErrInvalidSchedule):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_schedule",
+				Message: "expires_at must be in the future and after activates_at, and cannot be combined with expires_in",
+			})
 		default:
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
 				Error:   "internal_error",
@@ -78,39 +123,95 @@ func (h *Handler) CreateShortCode(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+// CreateShortCodesBatch create many short links in one request
+// @Summary Create short links in bulk
+// @Description Create up to 1000 short links. By default the batch is atomic (all or nothing); with partial=true the valid items are created and failures are reported per item.
+// @Tags shortcode
+// @Accept json
+// @Produce json
+// @Param request body model.BatchCreateShortCodeRequest true "Bulk create request"
+// @Success 201 {object} model.BatchCreateShortCodeResponse "All items created"
+// @Success 207 {object} model.BatchCreateShortCodeResponse "Partial mode, some items failed"
+// @Failure 400 {object} model.BatchCreateShortCodeResponse "Atomic mode, nothing created"
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/shorten/batch [post]
+func (h *Handler) CreateShortCodesBatch(c *gin.Context) {
+	var req model.BatchCreateShortCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.service.CreateShortCodesBatch(c.Request.Context(), &req, currentAPIKey(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create short codes",
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	switch {
+	case resp.Succeeded == 0 && resp.Failed > 0:
+		status = http.StatusBadRequest
+	case resp.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, resp)
+}
+
 // RedirectToOriginal redirect to original URL
 // @Summary Redirect to original URL
-// @Description Redirect to original URL based on short code
+// @Description Redirect to original URL based on short code. Password protected links serve a password form, API clients can send the password in the X-Link-Password header.
 // @Tags shortcode
 // @Param code path string true "Short code"
-// @Success 302 "Redirect to original URL"
-// @Failure 404 {object} ErrorResponse
+// @Param X-Link-Password header string false "Password of a protected link"
+// @Success 301 "Redirect to original URL (permanent, cacheable by browsers)"
+// @Success 302 "Redirect to original URL (default)"
+// @Success 307 "Redirect to original URL (temporary, method preserved)"
+// @Success 308 "Redirect to original URL (permanent, method preserved)"
+// @Failure 401 {object} ErrorResponse "Password required or wrong"
+// @Failure 404 {object} ErrorResponse "Not found or not active yet"
+// @Failure 410 {object} ErrorResponse "Expired, click limit reached or disabled, without fallback URL"
+// @Failure 429 {object} ErrorResponse "Too many failed password attempts"
 // @Router /{code} [get]
+// @Router /{code} [head]
 func (h *Handler) RedirectToOriginal(c *gin.Context) {
-	code := c.Param("code")
-
-	originalURL, err := h.service.GetOriginalURL(c.Request.Context(), code)
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "not_found",
-			Message: "Short code not found or expired",
-		})
+	target, ok := h.resolveRedirect(c, c.GetHeader(passwordHeader))
+	if !ok {
 		return
 	}
 
-	// Asynchronously record click
-	// Use context.Background() to avoid context cancellation after redirect
-	go func() {
-		ipAddress := c.ClientIP()
-		userAgent := c.GetHeader("User-Agent")
-		referer := c.GetHeader("Referer")
-		// Create a new context with timeout to avoid goroutine leak
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = h.service.RecordClick(ctx, code, ipAddress, userAgent, referer)
-	}()
+	h.recordClick(c, target)
+	c.Redirect(target.StatusCode, target.URL)
+}
+
+// recordClick hands the click of the current request to the click pipeline, the redirect never waits for the write
+func (h *Handler) recordClick(c *gin.Context, target *model.RedirectTarget) {
+	ctx, span := tracer.Start(c.Request.Context(), "Handler.recordClick", trace.WithAttributes(
+		attribute.Bool("shortcode.fallback", target.Fallback),
+		attribute.Bool("shortcode.bot", target.Bot),
+	))
+	defer span.End()
 
-	c.Redirect(http.StatusMovedPermanently, originalURL)
+	h.clicks.Enqueue(ctx, &model.ClickEvent{
+		Code:       c.Param("code"),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+		Referer:    c.GetHeader("Referer"),
+		OccurredAt: time.Now(),
+		Counted:    target.Counted,
+		Fallback:   target.Fallback,
+		Bot:        target.Bot,
+
+		TraceContext: telemetry.InjectTraceContext(ctx), // The recording span links back to this span
+	})
 }
 
 // GetStats get short link statistics
@@ -152,15 +253,16 @@ func (h *Handler) Health(c *gin.Context) {
 	})
 }
 
-// Metrics get service metrics
-// @Summary Get metrics
-// @Description Get service running metrics
+// Metrics get business totals
+// @Summary Get business totals
+// @Description Get link and click totals, computed at most once a minute. Operational metrics are served in the Prometheus format on /metrics.
 // @Tags system
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Router /metrics [get]
+// @Success 200 {object} model.MetricsSummary
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/metrics [get]
 func (h *Handler) Metrics(c *gin.Context) {
-	metrics, err := h.service.GetMetrics(c.Request.Context())
+	summary, err := h.service.GetMetrics(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "internal_error",
@@ -169,7 +271,7 @@ func (h *Handler) Metrics(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, metrics)
+	c.JSON(http.StatusOK, summary)
 }
 
 // DeleteShortCode delete short link
@@ -179,12 +281,17 @@ func (h *Handler) Metrics(c *gin.Context) {
 // @Produce json
 // @Param code path string true "Short code"
 // @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/shorten/{code} [delete]
 func (h *Handler) DeleteShortCode(c *gin.Context) {
 	code := c.Param("code")
 
-	if err := h.service.DeleteShortCode(c.Request.Context(), code); err != nil {
+	if err := h.service.DeleteShortCode(c.Request.Context(), code, currentAPIKey(c)); err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
 			Message: "Short code not found",
@@ -197,35 +304,288 @@ func (h *Handler) DeleteShortCode(c *gin.Context) {
 	})
 }
 
-// GetDetailedStats get detailed statistics with hourly buckets
+// ListShortCodes list short links
+// @Summary List short links
+// @Description List short links with filters and cursor-based pagination. Non-admin keys only see their own links.
+// @Tags shortcode
+// @Produce json
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param created_after query string false "Only links created at or after this time (RFC 3339)"
+// @Param created_before query string false "Only links created before this time (RFC 3339)"
+// @Param status query string false "active, scheduled or expired (active excludes disabled links)"
+// @Param has_clicks query bool false "Only links with (true) or without (false) clicks"
+// @Param owner_id query int false "Only links owned by this API key (admin only)"
+// @Param q query string false "Substring of the destination URL"
+// @Param sort query string false "created_at (default) or click_count"
+// @Param order query string false "desc (default) or asc"
+// @Success 200 {object} model.ListShortCodesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/shorten [get]
+func (h *Handler) ListShortCodes(c *gin.Context) {
+	var req model.ListShortCodesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.service.ListShortCodes(c.Request.Context(), &req, currentAPIKey(c))
+	if err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_cursor",
+				Message: "The cursor is malformed or does not match the sort order",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to list short codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateShortCode update short link
+// @Summary Update short link
+// @Description Change the destination, schedule or redirect type of an existing short link
+// @Tags shortcode
+// @Accept json
+// @Produce json
+// @Param code path string true "Short code"
+// @Param request body model.UpdateShortCodeRequest true "Update short link request"
+// @Success 200 {object} model.ShortCodeInfo
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/shorten/{code} [patch]
+func (h *Handler) UpdateShortCode(c *gin.Context) {
+	code := c.Param("code")
+
+	var req model.UpdateShortCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	info, err := h.service.UpdateShortCode(c.Request.Context(), code, &req, currentAPIKey(c))
+	if err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrCodeNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Short code not found",
+			})
+		case errors.Is(err, service.ErrInvalidURL):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_url",
+				Message: "The provided URL is not valid",
+			})
+		case errors.Is(err, service.ErrInvalidRedirectType):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_redirect_type",
+				Message: "The redirect type must be 301, 302, 307 or 308",
+			})
+		case errors.Is(err, service.ErrInvalidPassword):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_password",
+				Message: "The password must be 4-72 characters",
+			})
+		case errors.Is(err, service.ErrInvalidMaxClicks):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_max_clicks",
+				Message: "The click limit must be a positive number",
+			})
+		case errors.Is(err, service.ErrInvalidFallbackURL):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_fallback_url",
+				Message: "The fallback URL is not valid",
+			})
+		case errors.Is(err, service.ErrInvalidSchedule):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_schedule",
+				Message: "expires_at must be in the future and after activates_at, and cannot be combined with expires_in",
+			})
+		case errors.Is(err, service.ErrEmptyUpdate):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "empty_update",
+				Message: "No fields to update",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to update short code",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// GetDetailedStats get detailed statistics with a time series
 // @Summary Get detailed statistics
-// @Description Get detailed statistics including hourly access data and location information
+// @Description Get detailed statistics including a gap-filled click time series and location information
 // @Tags shortcode
 // @Produce json
 // @Param code path string true "Short code"
-// @Param hours query int false "Number of hours to look back (default: all time)"
+// @Param hours query int false "Number of hours to look back (default: all time), ignored if from is given"
+// @Param from query string false "Start of the range, RFC 3339 or YYYY-MM-DD in tz"
+// @Param to query string false "End of the range, RFC 3339 or YYYY-MM-DD in tz (whole day included), default now"
+// @Param granularity query string false "Time series buckets: minute, hour (default), day, week or month"
+// @Param tz query string false "IANA time zone the buckets and dates are in (default: UTC)"
+// @Param exclude_internal query bool false "Leave out private, loopback and reserved IP addresses"
 // @Success 200 {object} model.DetailedStats
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/stats/{code}/detailed [get]
 func (h *Handler) GetDetailedStats(c *gin.Context) {
 	code := c.Param("code")
 
-	// Get hours parameter (default to 0 = all time)
-	hours := 0
-	if hoursParam := c.Query("hours"); hoursParam != "" {
-		if h, err := time.ParseDuration(hoursParam + "h"); err == nil {
-			hours = int(h.Hours())
+	query, ok := parseStatsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetDetailedStats(c.Request.Context(), code, query, currentAPIKey(c))
+	if err != nil {
+		respondStatsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseStatsQuery parses the range, granularity, time zone and IP class filters of statistics,
+// reports false after responding 400 to invalid parameters
+func parseStatsQuery(c *gin.Context) (*model.StatsQuery, bool) {
+	query := &model.StatsQuery{Granularity: c.Query("granularity")}
+	query.ExcludeInternal, _ = strconv.ParseBool(c.Query("exclude_internal"))
+
+	query.Location = time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_timezone",
+				Message: "tz must be an IANA time zone name such as Europe/Berlin",
+			})
+			return nil, false
 		}
+		query.Location = loc
 	}
 
-	stats, err := h.service.GetDetailedStats(c.Request.Context(), code, hours)
+	var err error
+	if query.From, err = parseStatsTime(c.Query("from"), query.Location, false); err == nil {
+		query.To, err = parseStatsTime(c.Query("to"), query.Location, true)
+	}
 	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_time_range",
+			Message: "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates",
+		})
+		return nil, false
+	}
+
+	// Get hours parameter (default to 0 = all time)
+	if hoursParam := c.Query("hours"); hoursParam != "" && query.From.IsZero() {
+		if h, err := time.ParseDuration(hoursParam + "h"); err == nil && h > 0 {
+			query.From = time.Now().Add(-h)
+		}
+	}
+	return query, true
+}
+
+// respondStatsError writes the error response of a failed statistics request
+func respondStatsError(c *gin.Context, err error) {
+	if respondAccessError(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidGranularity):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_granularity",
+			Message: "granularity must be one of minute, hour, day, week or month",
+		})
+	case errors.Is(err, service.ErrInvalidTimeRange):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_time_range",
+			Message: "from must be before to",
+		})
+	case errors.Is(err, service.ErrTooManyBuckets):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "too_many_buckets",
+			Message: "The range has too many buckets for this granularity, narrow it or use a coarser granularity",
+		})
+	case errors.Is(err, service.ErrCodeNotFound):
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
 			Message: "Short code not found",
 		})
-		return
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get statistics",
+		})
 	}
+}
 
-	c.JSON(http.StatusOK, stats)
+// parseStatsTime parses a statistics range bound, an RFC 3339 timestamp or a date in loc.
+// A date as the end of the range includes that whole day.
+func parseStatsTime(value string, loc *time.Location, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// respondAccessError writes 401/403 responses for authorization errors, reports whether it handled the error
+func respondAccessError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrUnauthorized):
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: "An API key is required",
+		})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: "The API key is not allowed to access this resource",
+		})
+	default:
+		return false
+	}
+	return true
 }
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+const (
+	// liveHeartbeatInterval time between comments sent on idle streams, so proxies and clients keep the connection open
+	liveHeartbeatInterval = 15 * time.Second
+	// liveRetry reconnect delay suggested to EventSource clients, in milliseconds
+	liveRetry = 3000
+)
+
+// StreamClicks stream the clicks of a short link
+// @Summary Stream clicks live
+// @Description Server-Sent Events stream of the clicks of a short link as they are recorded, with location, IP class, parsed user agent and referrer. Each click is a "click" event with JSON data; idle streams get a comment every 15 seconds. Requires the API key that created the link or an admin key.
+// @Tags shortcode
+// @Produce text/event-stream
+// @Param code path string true "Short code"
+// @Success 200 {object} model.RecordedClick "Stream of click events"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/stats/{code}/live [get]
+func (h *Handler) StreamClicks(c *gin.Context) {
+	h.streamClicks(c, c.Param("code"))
+}
+
+// StreamAllClicks stream the clicks of all short links of the API key
+// @Summary Stream clicks of all links live
+// @Description Server-Sent Events stream of the clicks of every short link created with the API key, as they are recorded. Same events as the stream of a single link.
+// @Tags shortcode
+// @Produce text/event-stream
+// @Success 200 {object} model.RecordedClick "Stream of click events"
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/clicks/live [get]
+func (h *Handler) StreamAllClicks(c *gin.Context) {
+	h.streamClicks(c, "")
+}
+
+// streamClicks writes the clicks of a subscription as Server-Sent Events until the client leaves or the stream shuts down
+func (h *Handler) streamClicks(c *gin.Context, code string) {
+	clicks, stop, err := h.service.StreamClicks(c.Request.Context(), code, currentAPIKey(c))
+	if err != nil {
+		if respondAccessError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrCodeNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Short code not found",
+			})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "stream_unavailable",
+			Message: "The live click stream is not available",
+		})
+		return
+	}
+	defer stop()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Keep buffering proxies from holding events back
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprintf(c.Writer, "retry: %d\n\n", liveRetry)
+	if err := flushEvents(c); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case click, ok := <-clicks:
+			if !ok {
+				return // Server shutting down, EventSource clients reconnect to another replica
+			}
+			c.SSEvent("click", click)
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(c.Writer, ": ping\n\n")
+		}
+
+		if err := flushEvents(c); err != nil {
+			return
+		}
+	}
+}
+
+// flushEvents sends the buffered events and gives the client another write timeout, until the next heartbeat at the latest
+func flushEvents(c *gin.Context) error {
+	c.Writer.Flush()
+
+	return extendWriteDeadline(c, 2*liveHeartbeatInterval)
+}
@@ -2,32 +2,67 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/logging"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
 )
 
-// loggerMiddleware logger middleware
+// apiKeyContextKey gin context key of the authenticated API key
+const apiKeyContextKey = "APIKey"
+
+// loggerMiddleware writes one access log line per request, carrying the request fields of the context
 func loggerMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		statusColor := param.StatusCodeColor()
-		resetColor := param.ResetColor()
-
-		return fmt.Sprintf("%s | %s | %s | %s%3d%s | %13v | %15s | %s\n",
-			param.TimeStamp.Format(time.RFC3339),
-			param.Method,
-			param.Path,
-			statusColor,
-			param.StatusCode,
-			resetColor,
-			param.Latency,
-			param.ClientIP,
-			param.ErrorMessage,
-		)
-	})
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String("errors", errs))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+// metricsMiddleware records request counts and latency per route pattern, unmatched paths
+// share one label so scanners cannot create a series per URL
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
 }
 
 // corsMiddleware CORS middleware
@@ -35,8 +70,8 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -98,6 +133,15 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return false
 }
 
+// Exceeded reports whether the key has used up its requests of the current window, without counting a request
+func (rl *RateLimiter) Exceeded(key string) bool {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	v, exists := rl.visitors[key]
+	return exists && time.Now().Before(v.resetTime) && v.requests >= rl.rate
+}
+
 // cleanupVisitors periodically cleans up expired visitor records
 func (rl *RateLimiter) cleanupVisitors() {
 	ticker := time.NewTicker(time.Minute)
@@ -133,9 +177,14 @@ func rateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
 	}
 }
 
-// timeoutMiddleware request timeout middleware
-func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+// timeoutMiddleware request timeout middleware, streaming routes are exempt and manage their own deadlines
+func timeoutMiddleware(timeout time.Duration, streamingRoutes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if slices.Contains(streamingRoutes, c.FullPath()) {
+			c.Next()
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
 		defer cancel()
 
@@ -197,6 +246,13 @@ func requestIDMiddleware() gin.HandlerFunc {
 
 		c.Set("RequestID", requestID)
 		c.Writer.Header().Set("X-Request-ID", requestID)
+
+		// Log lines written while handling the request, down to the repository, carry these fields
+		fields := []any{"request_id", requestID, "client_ip", c.ClientIP()}
+		if code := c.Param("code"); code != "" {
+			fields = append(fields, "code", code)
+		}
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), fields...))
 		c.Next()
 	}
 }
@@ -211,3 +267,67 @@ func securityHeadersMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// authMiddleware resolves the API key sent with the request, anonymous requests pass through
+func authMiddleware(apiKeys service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := extractAPIKey(c.Request)
+		if rawKey == "" {
+			c.Next()
+			return
+		}
+
+		key, err := apiKeys.Authenticate(c.Request.Context(), rawKey)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				status = http.StatusUnauthorized
+			}
+			c.JSON(status, gin.H{
+				"error":   "invalid_api_key",
+				"message": "The provided API key is invalid or revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// requireAPIKeyMiddleware rejects requests that are not authenticated with an API key
+func requireAPIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentAPIKey(c) == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "unauthorized",
+				"message": "An API key is required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// extractAPIKey reads the API key from the Authorization bearer token or the X-API-Key header
+func extractAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// currentAPIKey returns the API key authenticated by authMiddleware, nil for anonymous requests
+func currentAPIKey(c *gin.Context) *model.APIKey {
+	value, exists := c.Get(apiKeyContextKey)
+	if !exists {
+		return nil
+	}
+	key, _ := value.(*model.APIKey)
+	return key
+}
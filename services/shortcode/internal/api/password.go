@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// passwordHeader header used by API clients to unlock protected links
+const passwordHeader = "X-Link-Password"
+
+// passwordForm page served for password protected links, posts back to the same URL
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f5f5f5; display: flex; justify-content: center; padding-top: 15vh; }
+form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.15); width: 320px; }
+input, button { width: 100%; box-sizing: border-box; padding: .6rem; margin-top: .8rem; font-size: 1rem; }
+.error { color: #c0392b; margin-top: .8rem; }
+</style>
+</head>
+<body>
+<form method="post">
+<h1>Password required</h1>
+<p>The link <strong>{{.Code}}</strong> is protected.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" placeholder="Password" autocomplete="off" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// UnlockShortCode submit the password of a protected link
+// @Summary Unlock protected short link
+// @Description Verify the password submitted by the password form and redirect to the original URL
+// @Tags shortcode
+// @Accept x-www-form-urlencoded
+// @Param code path string true "Short code"
+// @Param password formData string true "Link password"
+// @Success 303 "Redirect to original URL"
+// @Failure 401 "Password required or wrong"
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse "Expired, click limit reached or disabled, without fallback URL"
+// @Failure 429 "Too many failed password attempts"
+// @Router /{code} [post]
+func (h *Handler) UnlockShortCode(c *gin.Context) {
+	password := c.PostForm("password")
+	if password == "" {
+		password = c.GetHeader(passwordHeader)
+	}
+
+	target, ok := h.resolveRedirect(c, password)
+	if !ok {
+		return
+	}
+
+	h.recordClick(c, target)
+	// 303 turns the form POST into a GET, a 307/308 would resend the password to the destination
+	c.Redirect(http.StatusSeeOther, target.URL)
+}
+
+// resolveRedirect resolves the short link of the request and writes the error response if it cannot be followed
+func (h *Handler) resolveRedirect(c *gin.Context, password string) (*model.RedirectTarget, bool) {
+	ip := c.ClientIP()
+
+	// Refuse before comparing the hash so a locked out IP cannot keep guessing
+	if password != "" && h.passwordAttempts.Exceeded(ip) {
+		h.respondPassword(c, http.StatusTooManyRequests, "too_many_attempts", "Too many failed password attempts, please try again later")
+		return nil, false
+	}
+
+	target, err := h.service.ResolveRedirect(c.Request.Context(), c.Param("code"), password, h.bots.IsBot(c.Request))
+	switch {
+	case err == nil:
+		return target, true
+	case errors.Is(err, service.ErrPasswordRequired):
+		h.respondPassword(c, http.StatusUnauthorized, "password_required", "")
+	case errors.Is(err, service.ErrWrongPassword):
+		h.passwordAttempts.Allow(ip) // Count the failed attempt
+		h.respondPassword(c, http.StatusUnauthorized, "wrong_password", "Wrong password")
+	case errors.Is(err, service.ErrNotYetActive):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_yet_active",
+			Message: "This short link is not active yet",
+		})
+	case errors.Is(err, service.ErrLinkExpired):
+		c.JSON(http.StatusGone, ErrorResponse{
+			Error:   "expired",
+			Message: "This short link has expired",
+		})
+	case errors.Is(err, service.ErrLinkDisabled):
+		c.JSON(http.StatusGone, ErrorResponse{
+			Error:   "disabled",
+			Message: "This short link has been disabled",
+		})
+	case errors.Is(err, service.ErrLinkExhausted):
+		c.JSON(http.StatusGone, ErrorResponse{
+			Error:   "link_exhausted",
+			Message: "This short link has reached its click limit",
+		})
+	case errors.Is(err, service.ErrCodeNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: "Short code not found or expired",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to resolve short code",
+		})
+	}
+	return nil, false
+}
+
+// respondPassword answers a protected link request with the password form for browsers and JSON for API clients
+func (h *Handler) respondPassword(c *gin.Context, status int, code, message string) {
+	c.Header("Cache-Control", "no-store")
+
+	if c.GetHeader(passwordHeader) != "" || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) != gin.MIMEHTML {
+		if message == "" {
+			message = "This short link is password protected, send the password in the " + passwordHeader + " header"
+		}
+		c.JSON(status, ErrorResponse{
+			Error:   code,
+			Message: message,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	_ = passwordForm.Execute(c.Writer, gin.H{
+		"Code":  c.Param("code"),
+		"Error": message,
+	})
+}
@@ -1,21 +1,39 @@
 package api
 
 import (
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
 	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/lincyaw/tools/services/shortcode/internal/useragent"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // NewRouter creates router
-func NewRouter(service service.ShortCodeService) *gin.Engine {
+func NewRouter(service service.ShortCodeService, apiKeys service.APIKeyService, webhooks service.WebhookService, clicks service.ClickSink, bots *useragent.BotDetector, cfg *config.Config) *gin.Engine {
 	// Set to release mode to improve performance
 	// gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
 
+	// Take the client IP from the proxy header only for requests coming from a trusted proxy,
+	// otherwise any client could spoof it. Rate limiting, click logs and request logs all use it.
+	router.RemoteIPHeaders = []string{cfg.Proxy.ClientIPHeader}
+	if err := router.SetTrustedProxies(cfg.Proxy.TrustedProxies); err != nil {
+		slog.Warn("Invalid trusted proxies, trusting none", "error", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+
 	// Middleware chain
-	router.Use(gin.Recovery())              // Recovery middleware
+	router.Use(gin.Recovery()) // Recovery middleware
+	router.Use(otelgin.Middleware("shortcode", otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics" && r.URL.Path != "/health" // Scrapes and probes are not traced
+	}))) // Request spans, continuing the W3C trace context of the caller
+	router.Use(metricsMiddleware())         // Prometheus request metrics, sees the 500 of recovered panics
 	router.Use(errorHandlerMiddleware())    // Error handling middleware
 	router.Use(requestIDMiddleware())       // Request ID middleware
 	router.Use(loggerMiddleware())          // Logger middleware
@@ -24,25 +42,54 @@ func NewRouter(service service.ShortCodeService) *gin.Engine {
 
 	// Create rate limiter: 100 requests per minute
 	limiter := NewRateLimiter(100, time.Minute)
-	router.Use(rateLimitMiddleware(limiter))        // Rate limiting middleware
-	router.Use(timeoutMiddleware(30 * time.Second)) // Request timeout
+	router.Use(rateLimitMiddleware(limiter))     // Rate limiting middleware
+	router.Use(timeoutMiddleware(30*time.Second, // Request timeout, exports and live streams run for longer
+		"/api/v1/stats/:code/export/clicks", "/api/v1/stats/:code/export/timeseries",
+		"/api/v1/stats/:code/live", "/api/v1/clicks/live"))
 
-	handler := NewHandler(service)
+	handler := NewHandler(service, apiKeys, webhooks, clicks, bots, cfg.Links)
+
+	// Creation can be restricted to authenticated callers
+	createAuth := func(c *gin.Context) { c.Next() }
+	if cfg.Auth.RequireAPIKey {
+		createAuth = requireAPIKeyMiddleware()
+	}
 
 	v1 := router.Group("/api/v1")
+	v1.Use(authMiddleware(apiKeys)) // Resolve API key, anonymous requests pass through
 	{
-		v1.POST("/shorten", handler.CreateShortCode)
+		v1.POST("/shorten", createAuth, handler.CreateShortCode)
+		v1.POST("/shorten/batch", createAuth, handler.CreateShortCodesBatch)
+		v1.GET("/shorten", requireAPIKeyMiddleware(), handler.ListShortCodes)
 		v1.GET("/stats/:code/detailed", handler.GetDetailedStats) // Detailed stats must be before :code
+		v1.GET("/stats/:code/export/clicks", handler.ExportClicks)
+		v1.GET("/stats/:code/export/timeseries", handler.ExportTimeSeries)
+		v1.GET("/stats/:code/live", handler.StreamClicks)
+		v1.GET("/clicks/live", requireAPIKeyMiddleware(), handler.StreamAllClicks)
 		v1.GET("/stats/:code", handler.GetStats)
+		v1.PATCH("/shorten/:code", handler.UpdateShortCode)
 		v1.DELETE("/shorten/:code", handler.DeleteShortCode)
+		v1.POST("/keys", requireAPIKeyMiddleware(), handler.CreateAPIKey)
+		v1.DELETE("/keys/:id", requireAPIKeyMiddleware(), handler.RevokeAPIKey)
+		v1.POST("/webhooks", requireAPIKeyMiddleware(), handler.CreateWebhook)
+		v1.GET("/webhooks", requireAPIKeyMiddleware(), handler.ListWebhooks)
+		v1.GET("/webhooks/:id", requireAPIKeyMiddleware(), handler.GetWebhook)
+		v1.PATCH("/webhooks/:id", requireAPIKeyMiddleware(), handler.UpdateWebhook)
+		v1.DELETE("/webhooks/:id", requireAPIKeyMiddleware(), handler.DeleteWebhook)
+		v1.GET("/webhooks/:id/deliveries", requireAPIKeyMiddleware(), handler.ListWebhookDeliveries)
+		v1.GET("/webhooks/:id/dead-letters", requireAPIKeyMiddleware(), handler.ListWebhookDeadLetters)
+		v1.POST("/webhooks/:id/dead-letters/:letter_id/replay", requireAPIKeyMiddleware(), handler.ReplayWebhookDeadLetter)
+		v1.GET("/metrics", handler.Metrics) // Business totals, cached
 	}
 
 	// Health check
 	router.GET("/health", handler.Health)
-	router.GET("/metrics", handler.Metrics) // New metrics endpoint
+	router.GET("/metrics", gin.WrapH(metrics.Handler())) // Prometheus exposition
 
 	// Short link redirection (placed last to avoid conflicts)
 	router.GET("/:code", handler.RedirectToOriginal)
+	router.HEAD("/:code", handler.RedirectToOriginal) // Link unfurlers probe with HEAD, counted as bots
+	router.POST("/:code", handler.UnlockShortCode)    // Password form of protected links
 
 	return router
 }
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/lincyaw/tools/services/shortcode/internal/useragent"
+)
+
+// redirectingService resolves every code to the same destination
+type redirectingService struct {
+	service.ShortCodeService
+}
+
+func (redirectingService) ResolveRedirect(context.Context, string, string, bool) (*model.RedirectTarget, error) {
+	return &model.RedirectTarget{URL: "https://example.com", StatusCode: http.StatusFound}, nil
+}
+
+// recordingSink keeps the click events handed to the click pipeline
+type recordingSink struct {
+	mu     sync.Mutex
+	events []*model.ClickEvent
+}
+
+func (s *recordingSink) Enqueue(_ context.Context, event *model.ClickEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return true
+}
+
+func (s *recordingSink) Stats() service.ClickSinkStats {
+	return service.ClickSinkStats{}
+}
+
+func (s *recordingSink) last() *model.ClickEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) == 0 {
+		return nil
+	}
+	return s.events[len(s.events)-1]
+}
+
+// newProxyTestRouter builds the router trusting the proxies of the Docker network and an IPv6 range
+func newProxyTestRouter(t *testing.T) (*gin.Engine, *recordingSink) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sink := &recordingSink{}
+	cfg := &config.Config{
+		Links: config.LinkConfig{PasswordMaxAttempts: 5, PasswordLockoutWindow: time.Minute},
+		Proxy: config.ProxyConfig{
+			TrustedProxies: []string{"172.28.0.10", "fd00::/8"},
+			ClientIPHeader: "X-Real-IP",
+		},
+	}
+	router := NewRouter(redirectingService{}, nil, nil, sink, useragent.NewBotDetector(nil), cfg)
+	return router, sink
+}
+
+func redirectRequest(remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/promo1", nil)
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func TestRouterClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer forging headers",
+			remoteAddr: "203.0.113.5:40000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1", "X-Forwarded-For": "198.51.100.2"},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "Docker bridge gateway is not the proxy",
+			remoteAddr: "172.28.0.1:40000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "172.28.0.1",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "172.28.0.10:40000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy, other header ignored",
+			remoteAddr: "172.28.0.10:40000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.2"},
+			want:       "172.28.0.10",
+		},
+		{
+			name:       "trusted proxy, malformed header",
+			remoteAddr: "172.28.0.10:40000",
+			headers:    map[string]string{"X-Real-IP": "not-an-ip"},
+			want:       "172.28.0.10",
+		},
+		{
+			name:       "untrusted IPv6 peer",
+			remoteAddr: "[2001:db8::1]:40000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "trusted IPv6 proxy",
+			remoteAddr: "[fd00::2]:40000",
+			headers:    map[string]string{"X-Real-IP": "2001:db8::7"},
+			want:       "2001:db8::7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sink := newProxyTestRouter(t)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, redirectRequest(tt.remoteAddr, tt.headers))
+
+			if w.Code != http.StatusFound {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+			}
+			event := sink.last()
+			if event == nil {
+				t.Fatal("no click event recorded")
+			}
+			if event.IPAddress != tt.want {
+				t.Errorf("click IP = %q, want %q", event.IPAddress, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterRateLimitClientIP(t *testing.T) {
+	const limit = 100 // Requests per minute of NewRouter
+
+	t.Run("untrusted peer cannot spread requests over forged IPs", func(t *testing.T) {
+		router, _ := newProxyTestRouter(t)
+
+		for i := 0; i <= limit; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, redirectRequest("203.0.113.5:40000", map[string]string{
+				"X-Real-IP":       "198.51.100." + strconv.Itoa(i%250+1),
+				"X-Forwarded-For": "192.0.2." + strconv.Itoa(i%250+1),
+			}))
+			if i < limit && w.Code == http.StatusTooManyRequests {
+				t.Fatalf("request %d rate limited before the limit", i+1)
+			}
+			if i == limit && w.Code != http.StatusTooManyRequests {
+				t.Fatalf("request %d status = %d, want %d", i+1, w.Code, http.StatusTooManyRequests)
+			}
+		}
+	})
+
+	t.Run("clients behind the trusted proxy are limited separately", func(t *testing.T) {
+		router, _ := newProxyTestRouter(t)
+		viaProxy := func(clientIP string) int {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, redirectRequest("172.28.0.10:40000", map[string]string{"X-Real-IP": clientIP}))
+			return w.Code
+		}
+
+		for i := 0; i < limit; i++ {
+			if code := viaProxy("198.51.100.1"); code == http.StatusTooManyRequests {
+				t.Fatalf("request %d rate limited before the limit", i+1)
+			}
+		}
+		if code := viaProxy("198.51.100.1"); code != http.StatusTooManyRequests {
+			t.Errorf("client over the limit: status = %d, want %d", code, http.StatusTooManyRequests)
+		}
+		if code := viaProxy("198.51.100.2"); code == http.StatusTooManyRequests {
+			t.Error("other client behind the proxy was rate limited")
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// CreateWebhook register webhook
+// @Summary Register webhook
+// @Description Register an endpoint notified of the events of the links of the API key, or of all links for a global webhook (admin keys only). The signing secret is only returned once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body model.CreateWebhookRequest true "Register webhook request"
+// @Success 201 {object} model.CreateWebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req model.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.webhooks.CreateWebhook(c.Request.Context(), &req, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to register webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks list webhooks
+// @Summary List webhooks
+// @Description List the webhooks of the API key, admin keys see all webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} model.WebhookInfo
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [get]
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.ListWebhooks(c.Request.Context(), currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to list webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook get webhook
+// @Summary Get webhook
+// @Description Get a webhook, only its owner or an admin can see it
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} model.WebhookInfo
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [get]
+func (h *Handler) GetWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhooks.GetWebhook(c.Request.Context(), id, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to get webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook update webhook
+// @Summary Update webhook
+// @Description Change the URL, events or description of a webhook, or disable it
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body model.UpdateWebhookRequest true "Update webhook request"
+// @Success 200 {object} model.WebhookInfo
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [patch]
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	webhook, err := h.webhooks.UpdateWebhook(c.Request.Context(), id, &req, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to update webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook delete webhook
+// @Summary Delete webhook
+// @Description Delete a webhook, its pending deliveries are not sent
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.DeleteWebhook(c.Request.Context(), id, currentAPIKey(c)); err != nil {
+		respondWebhookError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries list webhook deliveries
+// @Summary List webhook deliveries
+// @Description Delivery log of a webhook with the payload, status, attempts and last response of each delivery, newest first
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (1-100, default 50)"
+// @Param status query string false "Delivery status (pending, succeeded, dead)"
+// @Param event_type query string false "Event type, e.g. link.created"
+// @Success 200 {object} model.ListWebhookDeliveriesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.ListWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.webhooks.ListDeliveries(c.Request.Context(), id, &req, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListWebhookDeadLetters list webhook dead letters
+// @Summary List webhook dead letters
+// @Description Deliveries of a webhook that failed on every attempt, newest first
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (1-100, default 50)"
+// @Success 200 {object} model.ListWebhookDeadLettersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/dead-letters [get]
+func (h *Handler) ListWebhookDeadLetters(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.ListWebhookDeadLettersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.webhooks.ListDeadLetters(c.Request.Context(), id, &req, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to list webhook dead letters")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReplayWebhookDeadLetter replay webhook dead letter
+// @Summary Replay webhook dead letter
+// @Description Queue a dead letter for delivery again with a fresh set of attempts, the dead letter is removed
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param letter_id path int true "Dead letter ID"
+// @Success 202 {object} model.WebhookDelivery
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/dead-letters/{letter_id}/replay [post]
+func (h *Handler) ReplayWebhookDeadLetter(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	letterID, ok := parseIDParam(c, "letter_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhooks.ReplayDeadLetter(c.Request.Context(), id, letterID, currentAPIKey(c))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to replay dead letter")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// parseIDParam parses a numeric ID path parameter, responding 400 if it is not a positive integer
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The " + name + " must be a positive integer",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondWebhookError writes the response of a webhook service error, unknown errors are reported with the given message
+func respondWebhookError(c *gin.Context, err error, message string) {
+	if respondAccessError(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: "Webhook not found",
+		})
+	case errors.Is(err, service.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: "Dead letter not found or already replayed",
+		})
+	case errors.Is(err, service.ErrInvalidWebhookURL):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_url",
+			Message: "The webhook URL must be an http or https URL of a public host",
+		})
+	case errors.Is(err, service.ErrInvalidEventType):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_event_type",
+			Message: "Unknown event type, expected link.created, link.updated, link.deleted, link.expired, link.clicked or *",
+		})
+	case errors.Is(err, service.ErrTooManyWebhooks):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "too_many_webhooks",
+			Message: "The API key has registered the maximum number of webhooks",
+		})
+	case errors.Is(err, service.ErrEmptyUpdate):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "empty_update",
+			Message: "No fields to update",
+		})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_cursor",
+			Message: "The cursor is malformed",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: message,
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
-	"log"
+	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config application configuration
@@ -13,6 +16,16 @@ type Config struct {
 	BaseURL     string
 	Database    DatabaseConfig
 	Redis       RedisConfig
+	Auth        AuthConfig
+	Links       LinkConfig
+	Sweeper     SweeperConfig
+	Clicks      ClickConfig
+	Geo         GeoConfig
+	Proxy       ProxyConfig
+	Bots        BotConfig
+	Tracing     TracingConfig
+	Log         LogConfig
+	Webhooks    WebhookConfig
 }
 
 // DatabaseConfig database configuration
@@ -22,6 +35,9 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	DBName   string
+
+	LogLevel           string        // Level of GORM logs: silent, error, warn (errors and slow queries) or info (every statement)
+	SlowQueryThreshold time.Duration // Statements taking longer are logged as slow queries, 0 disables
 }
 
 // RedisConfig Redis configuration
@@ -32,6 +48,96 @@ type RedisConfig struct {
 	DB       int
 }
 
+// AuthConfig API key authentication configuration
+type AuthConfig struct {
+	AdminAPIKey   string // Bootstrap admin key, registered on startup if set
+	RequireAPIKey bool   // Reject anonymous short link creation
+}
+
+// LinkConfig short link behavior configuration
+type LinkConfig struct {
+	DefaultRedirectType   int           // HTTP status used by links without an explicit redirect type
+	PasswordMaxAttempts   int           // Failed password attempts allowed per IP within the lockout window
+	PasswordLockoutWindow time.Duration // Window for counting failed password attempts
+	DefaultFallbackURL    string        // Destination of expired, exhausted or disabled links without their own fallback
+}
+
+// SweeperConfig background expiry sweeper configuration
+type SweeperConfig struct {
+	Interval  time.Duration // Time between runs, 0 disables the sweeper
+	BatchSize int           // Expired links handled per query
+	Mode      string        // none (cache eviction only), soft_delete or archive
+}
+
+// ClickConfig click ingestion pipeline configuration
+type ClickConfig struct {
+	QueueSize      int           // Buffered click events, further clicks are dropped when full
+	Workers        int           // Goroutines writing batches to the database
+	BatchSize      int           // Click events written per batch
+	FlushInterval  time.Duration // Maximum time a click event waits for its batch to fill
+	EnqueueTimeout time.Duration // How long a redirect waits for room in a full queue, 0 drops immediately
+
+	Transport        string        // memory (in-process queue) or redis_stream (durable Redis Stream)
+	Stream           string        // Redis Stream the click events are published to
+	ConsumerGroup    string        // Consumer group of the click workers
+	StreamMaxLen     int64         // Approximate length the stream is trimmed to
+	PublishTimeout   time.Duration // How long a redirect waits for the stream, the click is dropped on timeout
+	ConsumeInProcess bool          // Run the stream consumer in the server instead of a separate worker
+	ClaimIdle        time.Duration // Time after which unacknowledged events are taken over by another consumer
+	DedupRetention   time.Duration // How long recorded event IDs are kept to skip redeliveries
+
+	WorkerMetricsPort string // Port the standalone worker serves Prometheus metrics on, empty disables it
+}
+
+// GeoConfig IP geolocation configuration
+type GeoConfig struct {
+	Provider     string        // none, ipapi (ip-api.com web service) or maxmind (local .mmdb database)
+	DatabasePath string        // GeoLite2-City or GeoIP2-City database used by the maxmind provider
+	Timeout      time.Duration // Request timeout of the ipapi provider
+	CacheSize    int           // Locations kept in the LRU cache, 0 disables the cache
+	CacheTTL     time.Duration // How long a cached location is used
+}
+
+// ProxyConfig reverse proxies allowed to report the client IP
+type ProxyConfig struct {
+	TrustedProxies []string // IPs and CIDRs of trusted proxies, empty trusts none and uses the connection address
+	ClientIPHeader string   // Header the trusted proxies put the client IP in
+}
+
+// BotConfig bot detection configuration
+type BotConfig struct {
+	SignaturesFile  string   // File with one user agent signature per line, replaces the built-in list
+	ExtraSignatures []string // Signatures added to the built-in list or the file
+}
+
+// TracingConfig OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Exporter    string  // none, stdout (pretty printed spans, for local testing) or otlp (OTLP over HTTP)
+	SampleRatio float64 // Share of new traces recorded, requests with a sampled parent are always recorded
+}
+
+// WebhookConfig webhook delivery configuration
+type WebhookConfig struct {
+	Workers      int           // Deliveries sent concurrently by each server
+	PollInterval time.Duration // Time between checks for due deliveries
+	Timeout      time.Duration // Request timeout of a delivery attempt
+	MaxAttempts  int           // Attempts before a delivery is moved to the dead letters
+	RetryBase    time.Duration // Delay before the first retry, doubled on every further attempt
+	RetryMax     time.Duration // Longest delay between two attempts
+
+	ClickBatchSize   int           // Clicks per link.clicked event
+	ClickBatchWindow time.Duration // Longest a click waits for its link.clicked event
+
+	Retention           time.Duration // Age after which deliveries and dead letters are removed
+	AllowPrivateTargets bool          // Allow endpoints on private and loopback addresses, for local testing
+}
+
+// LogConfig logging configuration
+type LogConfig struct {
+	Level  slog.Level // Minimum level written
+	Format string     // json or text, JSON by default in production
+}
+
 // Load load configuration
 func Load() *Config {
 	cfg := &Config{
@@ -44,6 +150,9 @@ func Load() *Config {
 			User:     getEnv("DB_USER", "tools"),
 			Password: getEnv("DB_PASSWORD", "tools123"),
 			DBName:   getEnv("DB_NAME", "tools"),
+
+			LogLevel:           getEnv("DB_LOG_LEVEL", "warn"),
+			SlowQueryThreshold: time.Duration(getEnvAsInt("DB_SLOW_QUERY_MS", 200)) * time.Millisecond,
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -51,12 +160,239 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Auth: AuthConfig{
+			AdminAPIKey:   getEnv("ADMIN_API_KEY", ""),
+			RequireAPIKey: getEnvAsBool("REQUIRE_API_KEY", false),
+		},
+		Links: LinkConfig{
+			DefaultRedirectType:   getEnvAsInt("DEFAULT_REDIRECT_TYPE", 302),
+			PasswordMaxAttempts:   getEnvAsInt("PASSWORD_MAX_ATTEMPTS", 5),
+			PasswordLockoutWindow: time.Duration(getEnvAsInt("PASSWORD_LOCKOUT_MINUTES", 15)) * time.Minute,
+			DefaultFallbackURL:    getEnv("DEFAULT_FALLBACK_URL", ""),
+		},
+		Sweeper: SweeperConfig{
+			Interval:  time.Duration(getEnvAsInt("EXPIRY_SWEEP_INTERVAL_SECONDS", 300)) * time.Second,
+			BatchSize: getEnvAsInt("EXPIRY_SWEEP_BATCH_SIZE", 500),
+			Mode:      getEnv("EXPIRY_SWEEP_MODE", "none"),
+		},
+		Clicks: ClickConfig{
+			QueueSize:      getEnvAsInt("CLICK_QUEUE_SIZE", 10000),
+			Workers:        getEnvAsInt("CLICK_WORKERS", 4),
+			BatchSize:      getEnvAsInt("CLICK_BATCH_SIZE", 200),
+			FlushInterval:  time.Duration(getEnvAsInt("CLICK_FLUSH_INTERVAL_MS", 1000)) * time.Millisecond,
+			EnqueueTimeout: time.Duration(getEnvAsInt("CLICK_ENQUEUE_TIMEOUT_MS", 0)) * time.Millisecond,
+
+			Transport:        getEnv("CLICK_TRANSPORT", "memory"),
+			Stream:           getEnv("CLICK_STREAM", "shortcode:clicks"),
+			ConsumerGroup:    getEnv("CLICK_CONSUMER_GROUP", "click-recorders"),
+			StreamMaxLen:     int64(getEnvAsInt("CLICK_STREAM_MAXLEN", 1000000)),
+			PublishTimeout:   time.Duration(getEnvAsInt("CLICK_PUBLISH_TIMEOUT_MS", 500)) * time.Millisecond,
+			ConsumeInProcess: getEnvAsBool("CLICK_CONSUMER_IN_PROCESS", true),
+			ClaimIdle:        time.Duration(getEnvAsInt("CLICK_CLAIM_IDLE_SECONDS", 60)) * time.Second,
+			DedupRetention:   time.Duration(getEnvAsInt("CLICK_DEDUP_RETENTION_HOURS", 168)) * time.Hour,
+
+			WorkerMetricsPort: getEnv("WORKER_METRICS_PORT", ""),
+		},
+		Proxy: ProxyConfig{
+			TrustedProxies: parseTrustedProxies(getEnvAsList("TRUSTED_PROXIES")),
+			ClientIPHeader: getEnv("CLIENT_IP_HEADER", "X-Real-IP"),
+		},
+		Bots: BotConfig{
+			SignaturesFile:  getEnv("BOT_SIGNATURES_FILE", ""),
+			ExtraSignatures: getEnvAsList("BOT_SIGNATURES"),
+		},
+		Geo: GeoConfig{
+			Provider:     getEnv("GEOIP_PROVIDER", "ipapi"),
+			DatabasePath: getEnv("GEOIP_DATABASE", ""),
+			Timeout:      time.Duration(getEnvAsInt("GEOIP_TIMEOUT_MS", 2000)) * time.Millisecond,
+			CacheSize:    getEnvAsInt("GEOIP_CACHE_SIZE", 10000),
+			CacheTTL:     time.Duration(getEnvAsInt("GEOIP_CACHE_TTL_MINUTES", 1440)) * time.Minute,
+		},
+		Webhooks: WebhookConfig{
+			Workers:      getEnvAsInt("WEBHOOK_WORKERS", 4),
+			PollInterval: time.Duration(getEnvAsInt("WEBHOOK_POLL_INTERVAL_MS", 1000)) * time.Millisecond,
+			Timeout:      time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT_MS", 5000)) * time.Millisecond,
+			MaxAttempts:  getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 10),
+			RetryBase:    time.Duration(getEnvAsInt("WEBHOOK_RETRY_BASE_SECONDS", 30)) * time.Second,
+			RetryMax:     time.Duration(getEnvAsInt("WEBHOOK_RETRY_MAX_SECONDS", 3600)) * time.Second,
+
+			ClickBatchSize:   getEnvAsInt("WEBHOOK_CLICK_BATCH_SIZE", 100),
+			ClickBatchWindow: time.Duration(getEnvAsInt("WEBHOOK_CLICK_BATCH_WINDOW_SECONDS", 10)) * time.Second,
+
+			Retention:           time.Duration(getEnvAsInt("WEBHOOK_RETENTION_DAYS", 30)) * 24 * time.Hour,
+			AllowPrivateTargets: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+		},
+		Log: LogConfig{
+			Level:  parseLogLevel(getEnv("LOG_LEVEL", "info")),
+			Format: getEnv("LOG_FORMAT", ""),
+		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			SampleRatio: float64(getEnvAsInt("TRACING_SAMPLE_PERCENT", 100)) / 100,
+		},
+	}
+
+	switch cfg.Links.DefaultRedirectType {
+	case 301, 302, 307, 308:
+	default:
+		slog.Warn("Invalid DEFAULT_REDIRECT_TYPE, using 302", "value", cfg.Links.DefaultRedirectType)
+		cfg.Links.DefaultRedirectType = 302
+	}
+
+	switch cfg.Sweeper.Mode {
+	case "none", "soft_delete", "archive":
+	default:
+		slog.Warn("Invalid EXPIRY_SWEEP_MODE, using none", "value", cfg.Sweeper.Mode)
+		cfg.Sweeper.Mode = "none"
+	}
+	if cfg.Sweeper.BatchSize <= 0 {
+		slog.Warn("Invalid EXPIRY_SWEEP_BATCH_SIZE, using 500", "value", cfg.Sweeper.BatchSize)
+		cfg.Sweeper.BatchSize = 500
 	}
 
-	log.Printf("Configuration loaded: env=%s, port=%s", cfg.Environment, cfg.Port)
+	cfg.Clicks.QueueSize = atLeastOne("CLICK_QUEUE_SIZE", cfg.Clicks.QueueSize, 10000)
+	cfg.Clicks.Workers = atLeastOne("CLICK_WORKERS", cfg.Clicks.Workers, 4)
+	cfg.Clicks.BatchSize = atLeastOne("CLICK_BATCH_SIZE", cfg.Clicks.BatchSize, 200)
+	if cfg.Clicks.FlushInterval <= 0 {
+		slog.Warn("Invalid CLICK_FLUSH_INTERVAL_MS, using 1000")
+		cfg.Clicks.FlushInterval = time.Second
+	}
+	switch cfg.Clicks.Transport {
+	case "memory", "redis_stream":
+	default:
+		slog.Warn("Invalid CLICK_TRANSPORT, using memory", "value", cfg.Clicks.Transport)
+		cfg.Clicks.Transport = "memory"
+	}
+	if cfg.Clicks.PublishTimeout <= 0 {
+		slog.Warn("Invalid CLICK_PUBLISH_TIMEOUT_MS, using 500")
+		cfg.Clicks.PublishTimeout = 500 * time.Millisecond
+	}
+	if cfg.Clicks.ClaimIdle <= 0 {
+		slog.Warn("Invalid CLICK_CLAIM_IDLE_SECONDS, using 60")
+		cfg.Clicks.ClaimIdle = time.Minute
+	}
+	if cfg.Clicks.DedupRetention <= 0 {
+		slog.Warn("Invalid CLICK_DEDUP_RETENTION_HOURS, using 168")
+		cfg.Clicks.DedupRetention = 7 * 24 * time.Hour
+	}
+
+	switch cfg.Geo.Provider {
+	case "none", "ipapi", "maxmind":
+	default:
+		slog.Warn("Invalid GEOIP_PROVIDER, using ipapi", "value", cfg.Geo.Provider)
+		cfg.Geo.Provider = "ipapi"
+	}
+	if cfg.Geo.CacheSize < 0 {
+		slog.Warn("Invalid GEOIP_CACHE_SIZE, using 0", "value", cfg.Geo.CacheSize)
+		cfg.Geo.CacheSize = 0
+	}
+	if cfg.Geo.CacheTTL <= 0 {
+		slog.Warn("Invalid GEOIP_CACHE_TTL_MINUTES, using 1440")
+		cfg.Geo.CacheTTL = 24 * time.Hour
+	}
+
+	cfg.Webhooks.Workers = atLeastOne("WEBHOOK_WORKERS", cfg.Webhooks.Workers, 4)
+	cfg.Webhooks.MaxAttempts = atLeastOne("WEBHOOK_MAX_ATTEMPTS", cfg.Webhooks.MaxAttempts, 10)
+	cfg.Webhooks.ClickBatchSize = atLeastOne("WEBHOOK_CLICK_BATCH_SIZE", cfg.Webhooks.ClickBatchSize, 100)
+	if cfg.Webhooks.PollInterval <= 0 {
+		slog.Warn("Invalid WEBHOOK_POLL_INTERVAL_MS, using 1000")
+		cfg.Webhooks.PollInterval = time.Second
+	}
+	if cfg.Webhooks.Timeout <= 0 {
+		slog.Warn("Invalid WEBHOOK_TIMEOUT_MS, using 5000")
+		cfg.Webhooks.Timeout = 5 * time.Second
+	}
+	if cfg.Webhooks.RetryBase <= 0 {
+		slog.Warn("Invalid WEBHOOK_RETRY_BASE_SECONDS, using 30")
+		cfg.Webhooks.RetryBase = 30 * time.Second
+	}
+	if cfg.Webhooks.RetryMax < cfg.Webhooks.RetryBase {
+		slog.Warn("WEBHOOK_RETRY_MAX_SECONDS is below the retry base, using the base")
+		cfg.Webhooks.RetryMax = cfg.Webhooks.RetryBase
+	}
+	if cfg.Webhooks.ClickBatchWindow <= 0 {
+		slog.Warn("Invalid WEBHOOK_CLICK_BATCH_WINDOW_SECONDS, using 10")
+		cfg.Webhooks.ClickBatchWindow = 10 * time.Second
+	}
+	if cfg.Webhooks.Retention <= 0 {
+		slog.Warn("Invalid WEBHOOK_RETENTION_DAYS, using 30")
+		cfg.Webhooks.Retention = 30 * 24 * time.Hour
+	}
+
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = "text"
+		if cfg.Environment == "production" {
+			cfg.Log.Format = "json"
+		}
+	}
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		slog.Warn("Invalid LOG_FORMAT, using json", "value", cfg.Log.Format)
+		cfg.Log.Format = "json"
+	}
+	switch cfg.Database.LogLevel {
+	case "silent", "error", "warn", "info":
+	default:
+		slog.Warn("Invalid DB_LOG_LEVEL, using warn", "value", cfg.Database.LogLevel)
+		cfg.Database.LogLevel = "warn"
+	}
+	if cfg.Database.SlowQueryThreshold < 0 {
+		slog.Warn("Invalid DB_SLOW_QUERY_MS, using 0")
+		cfg.Database.SlowQueryThreshold = 0
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		slog.Warn("Invalid TRACING_EXPORTER, using none", "value", cfg.Tracing.Exporter)
+		cfg.Tracing.Exporter = "none"
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		slog.Warn("Invalid TRACING_SAMPLE_PERCENT, using 100")
+		cfg.Tracing.SampleRatio = 1
+	}
+
+	if len(cfg.Proxy.TrustedProxies) == 0 {
+		slog.Info("No trusted proxies configured, client IPs are taken from the connection")
+	}
+
+	slog.Info("Configuration loaded", "env", cfg.Environment, "port", cfg.Port)
 	return cfg
 }
 
+// parseLogLevel parses debug, info, warn or error, unknown levels fall back to info
+func parseLogLevel(value string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		slog.Warn("Invalid LOG_LEVEL, using info", "value", value)
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// parseTrustedProxies normalizes a list of IPs and CIDRs, invalid entries are skipped
+func parseTrustedProxies(entries []string) []string {
+	var proxies []string
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked().String())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			proxies = append(proxies, addr.String())
+		} else {
+			slog.Warn("Ignoring invalid TRUSTED_PROXIES entry", "value", entry)
+		}
+	}
+	return proxies
+}
+
+// atLeastOne returns value if it is positive, otherwise logs a warning and returns the default
+func atLeastOne(key string, value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	slog.Warn("Invalid setting, using the default", "key", key, "value", value, "default", defaultValue)
+	return defaultValue
+}
+
 // getEnv get environment variable, return default if not exists
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -74,7 +410,33 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	value, err := strconv.Atoi(valueStr)
 	if err != nil {
-		log.Printf("Warning: invalid integer value for %s, using default %d", key, defaultValue)
+		slog.Warn("Invalid integer setting, using the default", "key", key, "value", valueStr, "default", defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsList get comma separated environment variable, empty entries are skipped
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsBool get environment variable and convert to boolean
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		slog.Warn("Invalid boolean setting, using the default", "key", key, "value", valueStr, "default", defaultValue)
 		return defaultValue
 	}
 	return value
@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// Cached wraps a locator with an LRU cache of successful lookups
+type Cached struct {
+	locator service.GeoLocator
+	size    int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+}
+
+type cacheEntry struct {
+	ipAddress string
+	location  model.IPLocation
+	expiresAt time.Time
+}
+
+// NewCached creates LRU cached locator holding at most size locations for ttl each
+func NewCached(locator service.GeoLocator, size int, ttl time.Duration) *Cached {
+	return &Cached{
+		locator: locator,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// Locate returns the cached location or looks it up, failed lookups are not cached
+func (c *Cached) Locate(ctx context.Context, ipAddress string) (model.IPLocation, error) {
+	if location, ok := c.get(ipAddress); ok {
+		return location, nil
+	}
+
+	location, err := c.locator.Locate(ctx, ipAddress)
+	if err != nil {
+		return location, err
+	}
+
+	c.add(ipAddress, location)
+	return location, nil
+}
+
+// get returns a cached location that has not expired
+func (c *Cached) get(ipAddress string) (model.IPLocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[ipAddress]
+	if !ok {
+		return model.IPLocation{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, ipAddress)
+		return model.IPLocation{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.location, true
+}
+
+// add caches a location, evicting the least recently used one when full
+func (c *Cached) add(ipAddress string, location model.IPLocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[ipAddress]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.location = location
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).ipAddress)
+	}
+
+	c.entries[ipAddress] = c.order.PushFront(&cacheEntry{
+		ipAddress: ipAddress,
+		location:  location,
+		expiresAt: expiresAt,
+	})
+}
@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// Noop locator used when geolocation is disabled, every location is unknown
+type Noop struct{}
+
+// Locate returns an empty location
+func (Noop) Locate(context.Context, string) (model.IPLocation, error) {
+	return model.IPLocation{}, nil
+}
+
+// tracer creates the spans of provider lookups, cache hits have none
+var tracer = otel.Tracer("github.com/lincyaw/tools/services/shortcode/internal/geo")
+
+// timed records the latency of the lookups of a provider, wraps the provider inside the cache
+type timed struct {
+	locator  service.GeoLocator
+	provider string
+}
+
+// Locate looks up the location and records how long it took
+func (t timed) Locate(ctx context.Context, ipAddress string) (model.IPLocation, error) {
+	ctx, span := tracer.Start(ctx, "geo.Locate", trace.WithAttributes(attribute.String("geo.provider", t.provider)))
+	defer span.End()
+
+	start := time.Now()
+	location, err := t.locator.Locate(ctx, ipAddress)
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "lookup failed")
+	}
+	metrics.GeoLookupDuration.WithLabelValues(t.provider, result).Observe(time.Since(start).Seconds())
+	return location, err
+}
+
+// nopCloser closer of locators without resources
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// New creates the locator selected by the configuration, wrapped in a cache if enabled.
+// The returned closer releases the resources of the locator.
+func New(cfg config.GeoConfig) (service.GeoLocator, io.Closer, error) {
+	var locator service.GeoLocator
+	var closer io.Closer = nopCloser{}
+
+	switch cfg.Provider {
+	case "none":
+		slog.Info("GeoIP lookups disabled")
+		return Noop{}, closer, nil
+	case "maxmind":
+		if cfg.DatabasePath == "" {
+			return nil, nil, fmt.Errorf("GEOIP_PROVIDER=maxmind requires GEOIP_DATABASE")
+		}
+		maxMind, err := NewMaxMind(cfg.DatabasePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		locator, closer = maxMind, maxMind
+	case "ipapi":
+		locator = NewIPAPI(cfg.Timeout)
+	default:
+		return nil, nil, fmt.Errorf("unknown GeoIP provider %q", cfg.Provider)
+	}
+
+	locator = timed{locator: locator, provider: cfg.Provider}
+	if cfg.CacheSize > 0 {
+		locator = NewCached(locator, cfg.CacheSize, cfg.CacheTTL)
+	}
+
+	slog.Info("GeoIP lookups enabled", "provider", cfg.Provider, "cache_size", cfg.CacheSize)
+	return locator, closer, nil
+}
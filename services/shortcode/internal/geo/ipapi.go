@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// ErrRateLimited the free ip-api.com quota is used up, lookups are skipped until it resets
+var ErrRateLimited = errors.New("ip-api rate limit reached")
+
+// IPAPI resolves locations with the ip-api.com web service (free tier, 45 requests per minute)
+type IPAPI struct {
+	client *http.Client
+
+	mu           sync.Mutex
+	blockedUntil time.Time // Set when the quota is used up
+}
+
+// NewIPAPI creates ip-api.com locator
+func NewIPAPI(timeout time.Duration) *IPAPI {
+	return &IPAPI{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Locate looks up the location of an IP address
+func (l *IPAPI) Locate(ctx context.Context, ipAddress string) (model.IPLocation, error) {
+	l.mu.Lock()
+	blocked := time.Now().Before(l.blockedUntil)
+	l.mu.Unlock()
+	if blocked {
+		return model.IPLocation{}, ErrRateLimited
+	}
+
+	apiURL := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,message,country,regionName,city", ipAddress)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return model.IPLocation{}, err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return model.IPLocation{}, err
+	}
+	defer resp.Body.Close()
+
+	// X-Rl requests left in the current window, X-Ttl seconds until the window resets
+	if resp.Header.Get("X-Rl") == "0" || resp.StatusCode == http.StatusTooManyRequests {
+		ttl, _ := strconv.Atoi(resp.Header.Get("X-Ttl"))
+		l.mu.Lock()
+		l.blockedUntil = time.Now().Add(time.Duration(max(ttl, 1)) * time.Second)
+		l.mu.Unlock()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return model.IPLocation{}, fmt.Errorf("ip-api returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status     string `json:"status"`
+		Message    string `json:"message"`
+		Country    string `json:"country"`
+		RegionName string `json:"regionName"`
+		City       string `json:"city"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return model.IPLocation{}, err
+	}
+	if result.Status != "success" {
+		return model.IPLocation{}, fmt.Errorf("ip-api lookup failed: %s", result.Message)
+	}
+
+	return model.IPLocation{
+		Country: result.Country,
+		Region:  result.RegionName,
+		City:    result.City,
+	}, nil
+}
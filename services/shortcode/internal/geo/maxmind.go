@@ -0,0 +1,51 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// MaxMind resolves locations offline from a GeoLite2-City or GeoIP2-City .mmdb database
+type MaxMind struct {
+	reader *geoip2.Reader
+}
+
+// NewMaxMind opens the database at path, the file is memory mapped until Close
+func NewMaxMind(path string) (*MaxMind, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	return &MaxMind{reader: reader}, nil
+}
+
+// Locate looks up the location of an IP address, addresses missing from the database have an empty location
+func (l *MaxMind) Locate(_ context.Context, ipAddress string) (model.IPLocation, error) {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return model.IPLocation{}, fmt.Errorf("invalid IP address %q", ipAddress)
+	}
+
+	record, err := l.reader.City(ip)
+	if err != nil {
+		return model.IPLocation{}, err
+	}
+
+	location := model.IPLocation{
+		Country: record.Country.Names["en"],
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+	}
+	return location, nil
+}
+
+// Close releases the database
+func (l *MaxMind) Close() error {
+	return l.reader.Close()
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+)
+
+// fieldsKey context key of the fields added by With
+type fieldsKey struct{}
+
+// Setup installs the default logger: JSON lines in production, text otherwise unless LOG_FORMAT
+// says differently. Lines written by the standard log package go through it as well.
+func Setup(cfg config.LogConfig) {
+	options := &slog.HandlerOptions{Level: cfg.Level}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// With returns a context whose log lines carry the given fields, e.g. the request ID.
+// Fields already in the context are kept.
+func With(ctx context.Context, args ...any) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	record := slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+	record.Add(args...)
+
+	merged := make([]slog.Attr, 0, len(fields)+record.NumAttrs())
+	merged = append(merged, fields...)
+	record.Attrs(func(attr slog.Attr) bool {
+		merged = append(merged, attr)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// Fatal logs the message at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the fields of the context and the current trace to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields, ok := ctx.Value(fieldsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(fields...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
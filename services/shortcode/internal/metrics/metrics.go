@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefix of all shortcode metrics
+const namespace = "shortcode"
+
+var (
+	// HTTPRequests counts handled requests by route pattern, method and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration request latency by route pattern, method and status
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"route", "method", "status"})
+
+	// RedirectCacheLookups counts short link cache lookups of redirects by result (hit, miss)
+	RedirectCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirect_cache_lookups_total",
+		Help:      "Short link cache lookups of redirects by result (hit or miss).",
+	}, []string{"result"})
+
+	// GeoLookupDuration latency of IP geolocation provider lookups by provider and result (ok, error)
+	GeoLookupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "geo_lookup_duration_seconds",
+		Help:      "IP geolocation provider lookup latency by provider and result (ok or error), cache hits excluded.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"provider", "result"})
+
+	// WebhookDeliveries counts webhook delivery attempts by result (succeeded, retry, dead)
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result (succeeded, retry or dead).",
+	}, []string{"result"})
+)
+
+// ClickPipeline counters of the click pipeline, read on every scrape
+type ClickPipeline func() (enqueued, dropped, processed, failed uint64, pending int)
+
+// clickPipelineCollector exposes the click pipeline counters and queue depth
+type clickPipelineCollector struct {
+	stats     ClickPipeline
+	enqueued  *prometheus.Desc
+	dropped   *prometheus.Desc
+	processed *prometheus.Desc
+	failed    *prometheus.Desc
+	pending   *prometheus.Desc
+}
+
+// RegisterClickPipeline registers the counters of the click pipeline
+func RegisterClickPipeline(stats ClickPipeline) {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "clicks", name), help, nil, nil)
+	}
+	prometheus.MustRegister(&clickPipelineCollector{
+		stats:     stats,
+		enqueued:  desc("enqueued_total", "Click events accepted by the click pipeline."),
+		dropped:   desc("dropped_total", "Click events dropped because the queue was full or the stream unavailable."),
+		processed: desc("processed_total", "Click events written to the database by the in-process queue."),
+		failed:    desc("failed_total", "Click events the in-process queue failed to write."),
+		pending:   desc("pending", "Click events waiting in the in-process queue."),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *clickPipelineCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.enqueued
+	ch <- c.dropped
+	ch <- c.processed
+	ch <- c.failed
+	ch <- c.pending
+}
+
+// Collect implements prometheus.Collector
+func (c *clickPipelineCollector) Collect(ch chan<- prometheus.Metric) {
+	enqueued, dropped, processed, failed, pending := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.enqueued, prometheus.CounterValue, float64(enqueued))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(dropped))
+	ch <- prometheus.MustNewConstMetric(c.processed, prometheus.CounterValue, float64(processed))
+	ch <- prometheus.MustNewConstMetric(c.failed, prometheus.CounterValue, float64(failed))
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(pending))
+}
+
+// RegisterDBStats registers the connection pool stats of the database
+func RegisterDBStats(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
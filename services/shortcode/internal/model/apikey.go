@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey API key model, only the SHA-256 hash of the key is stored
+type APIKey struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Name       string         `gorm:"size:100;not null" json:"name"`
+	KeyPrefix  string         `gorm:"size:16;not null" json:"key_prefix"` // First characters of the key, for identification
+	KeyHash    string         `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	IsAdmin    bool           `gorm:"default:false;not null" json:"is_admin"`
+	CreatedAt  time.Time      `json:"created_at"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specify table name
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// CreateAPIKeyRequest create API key request
+type CreateAPIKeyRequest struct {
+	Name    string `json:"name" binding:"required,max=100"`
+	IsAdmin bool   `json:"is_admin,omitempty"`
+}
+
+// CreateAPIKeyResponse create API key response, the plain key is only returned once
+type CreateAPIKeyResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Key       string    `json:"key"`
+	KeyPrefix string    `json:"key_prefix"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
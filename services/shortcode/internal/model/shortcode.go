@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,9 +15,21 @@ type ShortCode struct {
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	ExpiresAt      *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	ActivatesAt    *time.Time     `gorm:"index" json:"activates_at,omitempty"` // Redirects start at this time, nil for immediately
 	ClickCount     int64          `gorm:"default:0" json:"click_count"`
+	BotClickCount  int64          `gorm:"default:0" json:"bot_click_count"` // Redirects of crawlers and link unfurlers, not included in ClickCount
 	LastAccessedAt *time.Time     `json:"last_accessed_at,omitempty"`
+	OwnerID        *uint          `gorm:"index" json:"owner_id,omitempty"`         // API key that created the link, nil for anonymous links
+	RedirectType   int            `gorm:"default:0;not null" json:"redirect_type"` // HTTP redirect status, 0 uses the service default
+	PasswordHash   string         `gorm:"size:100" json:"-"`                       // bcrypt hash, empty for links without password
+	MaxClicks      *int64         `json:"max_clicks,omitempty"`                    // Redirects allowed before the link is exhausted, nil for unlimited
+	FallbackURL    string         `gorm:"type:text" json:"fallback_url,omitempty"` // Destination once the link is expired, exhausted or disabled
+	FallbackCount  int64          `gorm:"default:0" json:"fallback_count"`         // Redirects to the fallback URL, not included in ClickCount
+	Disabled       bool           `gorm:"default:false;not null" json:"disabled"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ExpiryHandledAt  *time.Time `json:"-"` // When the expiry sweeper last handled the link, it is handled again after a later expiry
+	ExpiryReportedAt *time.Time `json:"-"` // When link.expired was last sent for the link, it is sent again after a later expiry
 }
 
 // TableName specify table name
@@ -24,6 +37,35 @@ func (ShortCode) TableName() string {
 	return "short_codes"
 }
 
+// ArchivedShortCode expired short link moved out of the live table by the expiry sweeper
+type ArchivedShortCode struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement:false" json:"id"` // ID of the original short link
+	Code           string     `gorm:"index;size:50;not null" json:"code"`
+	OriginalURL    string     `gorm:"type:text;not null" json:"original_url"`
+	OwnerID        *uint      `gorm:"index" json:"owner_id,omitempty"`
+	ClickCount     int64      `json:"click_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	ArchivedAt     time.Time  `gorm:"index;not null" json:"archived_at"`
+}
+
+// TableName specify table name
+func (ArchivedShortCode) TableName() string {
+	return "archived_short_codes"
+}
+
+// ProcessedClickEvent durable click event that was already recorded, redeliveries of it are skipped
+type ProcessedClickEvent struct {
+	EventID     string    `gorm:"primaryKey;size:64"`
+	ProcessedAt time.Time `gorm:"index;not null"`
+}
+
+// TableName specify table name
+func (ProcessedClickEvent) TableName() string {
+	return "processed_click_events"
+}
+
 // ClickLog click log model
 type ClickLog struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -32,9 +74,39 @@ type ClickLog struct {
 	IPAddress   string    `gorm:"size:45" json:"ip_address"`
 	UserAgent   string    `gorm:"type:text" json:"user_agent"`
 	Referer     string    `gorm:"type:text" json:"referer"`
-	CreatedAt   time.Time `json:"created_at"`
+	IsBot       bool      `gorm:"index;default:false;not null" json:"is_bot"`
+	// Parsed from the user agent when the click is recorded
+	Browser        string `gorm:"size:50" json:"browser"`
+	BrowserVersion string `gorm:"size:20" json:"browser_version"`
+	OS             string `gorm:"size:50" json:"os"`
+	OSVersion      string `gorm:"size:20" json:"os_version"`
+	Device         string `gorm:"size:16;index" json:"device"`
+	// Normalized from the referrer when the click is recorded
+	ReferrerSource string    `gorm:"size:100" json:"referrer_source"`
+	ReferrerGroup  string    `gorm:"size:16;index" json:"referrer_group"`
+	UTMSource      string    `gorm:"size:100" json:"utm_source"`
+	UTMMedium      string    `gorm:"size:100" json:"utm_medium"`
+	UTMCampaign    string    `gorm:"size:100" json:"utm_campaign"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
+// Device classes of click logs
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown" // Empty or unrecognized user agent
+)
+
+// Referrer groups of click logs
+const (
+	ReferrerSearch = "search"
+	ReferrerSocial = "social"
+	ReferrerOther  = "other"
+	ReferrerDirect = "direct" // No referrer
+)
+
 // TableName specify table name
 func (ClickLog) TableName() string {
 	return "click_logs"
@@ -42,39 +114,263 @@ func (ClickLog) TableName() string {
 
 // CreateShortCodeRequest create short link request
 type CreateShortCodeRequest struct {
-	URL        string `json:"url" binding:"required,url"`
-	CustomCode string `json:"custom_code,omitempty" binding:"omitempty,min=4,max=50,alphanum"`
-	ExpiresIn  int    `json:"expires_in,omitempty" binding:"omitempty,min=1"` // Expiration time (hours)
+	URL          string     `json:"url" binding:"required,url"`
+	CustomCode   string     `json:"custom_code,omitempty" binding:"omitempty,min=4,max=50,alphanum"`
+	ExpiresIn    int        `json:"expires_in,omitempty" binding:"omitempty,min=1"`                    // Expiration time (hours)
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`                                              // Absolute expiration time (RFC 3339), instead of expires_in
+	ActivatesAt  *time.Time `json:"activates_at,omitempty"`                                            // Redirects start at this time (RFC 3339)
+	RedirectType int        `json:"redirect_type,omitempty" binding:"omitempty,oneof=301 302 307 308"` // HTTP redirect status, defaults to the service default
+	Password     string     `json:"password,omitempty" binding:"omitempty,min=4,max=72"`               // Required to follow the link
+	MaxClicks    int64      `json:"max_clicks,omitempty" binding:"omitempty,min=1"`                    // Redirects allowed before the link is exhausted, 1 for one-time links
+	FallbackURL  string     `json:"fallback_url,omitempty" binding:"omitempty,url"`                    // Destination once the link is expired, exhausted or disabled
+}
+
+// UpdateShortCodeRequest update short link request, nil fields are left unchanged
+type UpdateShortCodeRequest struct {
+	URL          *string      `json:"url,omitempty" binding:"omitempty,url"`
+	ExpiresIn    *int         `json:"expires_in,omitempty" binding:"omitempty,min=0"`                      // Expiration time (hours from now), 0 removes the expiration
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`                                                // New absolute expiration time (RFC 3339), instead of expires_in
+	ActivatesAt  OptionalTime `json:"activates_at" swaggertype:"string"`                                   // New activation time (RFC 3339), a past time activates the link now, null removes it
+	RedirectType *int         `json:"redirect_type,omitempty" binding:"omitempty,oneof=0 301 302 307 308"` // HTTP redirect status, 0 resets to the service default
+	Password     *string      `json:"password,omitempty" binding:"omitempty,max=72"`                       // New password, empty string removes the password
+	MaxClicks    *int64       `json:"max_clicks,omitempty" binding:"omitempty,min=0"`                      // New click limit, 0 removes the limit
+	FallbackURL  *string      `json:"fallback_url,omitempty"`                                              // New fallback URL, empty string removes it
+	Disabled     *bool        `json:"disabled,omitempty"`                                                  // Disabled links redirect to the fallback URL
+}
+
+// OptionalTime time of a partial update that tells an explicit null apart from an absent field
+type OptionalTime struct {
+	Set  bool       // The field was in the request
+	Time *time.Time // nil when it was null, empty or the zero time
+}
+
+// UnmarshalJSON accepts an RFC 3339 time, or null, an empty string or the zero time to clear it
+func (t *OptionalTime) UnmarshalJSON(data []byte) error {
+	t.Set, t.Time = true, nil
+	if string(data) == "null" || string(data) == `""` {
+		return nil
+	}
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if !value.IsZero() {
+		t.Time = &value
+	}
+	return nil
 }
 
 // CreateShortCodeResponse create short link response
 type CreateShortCodeResponse struct {
-	ShortCode   string     `json:"short_code"`
-	ShortURL    string     `json:"short_url"`
-	OriginalURL string     `json:"original_url"`
-	CreatedAt   time.Time  `json:"created_at"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ShortCode         string     `json:"short_code"`
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt       *time.Time `json:"activates_at,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	FallbackURL       string     `json:"fallback_url,omitempty"`
+}
+
+// BatchCreateShortCodeRequest bulk create short links request
+type BatchCreateShortCodeRequest struct {
+	Items   []CreateShortCodeRequest `json:"items" binding:"required,min=1,max=1000"`
+	Partial bool                     `json:"partial,omitempty"` // Create the valid items even if others fail
+}
+
+// BatchCreateResult result of one item of a bulk create request
+type BatchCreateResult struct {
+	Index   int                      `json:"index"`
+	Success bool                     `json:"success"`
+	Result  *CreateShortCodeResponse `json:"result,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+	Message string                   `json:"message,omitempty"`
+}
+
+// BatchCreateShortCodeResponse bulk create short links response
+type BatchCreateShortCodeResponse struct {
+	Results   []BatchCreateResult `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+// ShortCodeInfo short link details
+type ShortCodeInfo struct {
+	Code              string     `json:"code"`
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt       *time.Time `json:"activates_at,omitempty"`
+	ClickCount        int64      `json:"click_count"`
+	LastAccessedAt    *time.Time `json:"last_accessed_at,omitempty"`
+	OwnerID           *uint      `json:"owner_id,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	RemainingClicks   *int64     `json:"remaining_clicks,omitempty"`
+	FallbackURL       string     `json:"fallback_url,omitempty"`
+	FallbackCount     int64      `json:"fallback_count"`
+	Disabled          bool       `json:"disabled"`
+}
+
+// RedirectTarget resolved redirect of a short link
+type RedirectTarget struct {
+	URL        string
+	StatusCode int
+	Counted    bool // The click count was already incremented while enforcing the click limit
+	Bot        bool // The request comes from a crawler or link unfurler
+	Fallback   bool // The link is not live, URL is the fallback destination
+}
+
+// ClickEvent click on a short link, recorded after the redirect
+type ClickEvent struct {
+	ID         string    `json:"-"` // Stream entry ID of durable events, empty for in-process events
+	Code       string    `json:"code"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Counted    bool      `json:"counted,omitempty"`  // Click count already incremented by the redirect
+	Fallback   bool      `json:"fallback,omitempty"` // Redirected to the fallback URL, only the fallback count is updated
+	Bot        bool      `json:"bot,omitempty"`      // Counted in the bot click count instead of the click count
+
+	TraceContext map[string]string `json:"trace_context,omitempty"` // W3C trace context of the redirect, linked from the recording span
+}
+
+// ClickCountDelta clicks of one short link aggregated from a batch of click events
+type ClickCountDelta struct {
+	ShortCodeID    uint
+	Clicks         int64
+	BotClicks      int64
+	Fallbacks      int64     // Fallback redirects, counted apart from the clicks
+	LastAccessedAt time.Time // Latest human click, zero if the batch only has bot clicks
+}
+
+// ShortCodeRef ID and owner of a short link, resolved for a batch of click events
+type ShortCodeRef struct {
+	ID      uint
+	OwnerID *uint
+}
+
+// ClickBatch database writes of a batch of click events, saved in one transaction
+type ClickBatch struct {
+	EventIDs []string // Durable events of the batch, marked as processed
+	Logs     []*ClickLog
+	Clicks   []ClickCountDelta // Ordered by short link ID
+	Accesses []*AccessStatistics
+}
+
+// RecordedClick click as reported to webhooks and the live click stream, once recorded with its
+// location and parsed user agent. The IP address is left out.
+type RecordedClick struct {
+	Code           string    `json:"code"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	Bot            bool      `json:"bot"`
+	IPClass        string    `json:"ip_class"`
+	Country        string    `json:"country,omitempty"`
+	Region         string    `json:"region,omitempty"`
+	City           string    `json:"city,omitempty"`
+	Browser        string    `json:"browser,omitempty"`
+	OS             string    `json:"os,omitempty"`
+	Device         string    `json:"device"`
+	ReferrerSource string    `json:"referrer_source"`
+	ReferrerGroup  string    `json:"referrer_group"`
+	UTMSource      string    `json:"utm_source,omitempty"`
+	UTMMedium      string    `json:"utm_medium,omitempty"`
+	UTMCampaign    string    `json:"utm_campaign,omitempty"`
+	OwnerID        *uint     `json:"-"` // Owner of the link, selects the webhooks and live streams of the click
+}
+
+// ListShortCodesRequest list short links query
+type ListShortCodesRequest struct {
+	Cursor        string     `form:"cursor"`
+	Limit         int        `form:"limit" binding:"omitempty,min=1,max=100"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Status        string     `form:"status" binding:"omitempty,oneof=active scheduled expired"`
+	HasClicks     *bool      `form:"has_clicks"`
+	OwnerID       *uint      `form:"owner_id"`                      // Only honored for admin keys
+	Query         string     `form:"q" binding:"omitempty,max=200"` // Substring of the destination URL
+	Sort          string     `form:"sort" binding:"omitempty,oneof=created_at click_count"`
+	Order         string     `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// ListShortCodesResponse list short links response
+type ListShortCodesResponse struct {
+	Items      []ShortCodeInfo `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// ShortCodeFilter repository filter for listing short links
+type ShortCodeFilter struct {
+	OwnerID       *uint
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Status        string
+	HasClicks     *bool
+	URLContains   string
+	SortBy        string // created_at or click_count
+	Ascending     bool
+	Limit         int
+	After         *ShortCodeCursor
+}
+
+// ShortCodeCursor position of the last item of a page, for keyset pagination
+type ShortCodeCursor struct {
+	SortBy     string    `json:"s"`
+	CreatedAt  time.Time `json:"t"`
+	ClickCount int64     `json:"c,omitempty"`
+	ID         uint      `json:"id"`
+}
+
+// ExpiryCursor position of the last expired link handled by the expiry sweeper
+type ExpiryCursor struct {
+	ExpiresAt time.Time
+	ID        uint
+}
+
+// MetricsSummary business totals of the service, cached for a minute
+type MetricsSummary struct {
+	TotalCodes  int64     `json:"total_codes"`
+	TotalClicks int64     `json:"total_clicks"`
+	ActiveCodes int64     `json:"active_codes"` // Short links clicked at least once
+	Clicks24h   int64     `json:"clicks_24h"`
+	GeneratedAt time.Time `json:"generated_at"`
 }
 
 // ShortCodeStats short link statistics
 type ShortCodeStats struct {
-	Code           string     `json:"code"`
-	OriginalURL    string     `json:"original_url"`
-	ClickCount     int64      `json:"click_count"`
-	CreatedAt      time.Time  `json:"created_at"`
-	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	Code              string     `json:"code"`
+	OriginalURL       string     `json:"original_url"`
+	ClickCount        int64      `json:"click_count"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastAccessedAt    *time.Time `json:"last_accessed_at,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	RemainingClicks   *int64     `json:"remaining_clicks,omitempty"`
+	FallbackCount     int64      `json:"fallback_count"`
+	Disabled          bool       `json:"disabled"`
+	BotClickCount     int64      `json:"bot_click_count"`
 }
 
 // AccessStatistics access statistics with hourly buckets
 type AccessStatistics struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
-	ShortCodeID uint      `gorm:"index:idx_shortcode_hour_ip;not null" json:"short_code_id"`
+	ShortCodeID uint      `gorm:"uniqueIndex:idx_access_statistics_key,priority:1;not null" json:"short_code_id"`
 	ShortCode   ShortCode `gorm:"foreignKey:ShortCodeID" json:"-"`
-	IPAddress   string    `gorm:"size:45;index:idx_shortcode_hour_ip" json:"ip_address"`
+	IPAddress   string    `gorm:"size:45;uniqueIndex:idx_access_statistics_key,priority:2" json:"ip_address"`
 	Country     string    `gorm:"size:100" json:"country"`
 	Region      string    `gorm:"size:100" json:"region"`
 	City        string    `gorm:"size:100" json:"city"`
-	HourBucket  time.Time `gorm:"index:idx_shortcode_hour_ip;not null" json:"hour_bucket"` // Time truncated to hour
+	IPClass     string    `gorm:"size:16;index;default:''" json:"ip_class"`                                              // Empty for rows recorded before classification
+	IsBot       bool      `gorm:"default:false;not null;uniqueIndex:idx_access_statistics_key,priority:4" json:"is_bot"` // Bot and human accesses of an IP are counted apart
+	HourBucket  time.Time `gorm:"not null;uniqueIndex:idx_access_statistics_key,priority:3" json:"hour_bucket"`          // Time truncated to hour
 	AccessCount int64     `gorm:"default:0;not null" json:"access_count"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -85,6 +381,35 @@ func (AccessStatistics) TableName() string {
 	return "access_statistics"
 }
 
+// IP address classes stored on access statistics
+const (
+	IPClassPublic   = "public"
+	IPClassPrivate  = "private"  // RFC 1918, CGNAT, IPv6 unique local and link-local addresses
+	IPClassLoopback = "loopback" // 127.0.0.0/8 and ::1
+	IPClassReserved = "reserved" // Unspecified, multicast, documentation, benchmarking and other special-purpose ranges, or not an IP address
+)
+
+// InternalIPClasses classes of traffic that does not come from the public internet
+var InternalIPClasses = []string{IPClassPrivate, IPClassLoopback, IPClassReserved}
+
+// StatsQuery filters of detailed statistics
+type StatsQuery struct {
+	From            time.Time      // Inclusive start, zero = since the link was created
+	To              time.Time      // Exclusive end, zero = now
+	Granularity     string         // Time series bucket size, one of the Granularity* values, default hour
+	Location        *time.Location // Time zone the buckets are aligned to, default UTC
+	ExcludeInternal bool           // Leave out private, loopback and reserved addresses
+}
+
+// Time series granularities of detailed statistics
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+	GranularityWeek   = "week" // Starting on Monday
+	GranularityMonth  = "month"
+)
+
 // IPLocation IP location information
 type IPLocation struct {
 	Country string `json:"country"`
@@ -94,21 +419,57 @@ type IPLocation struct {
 
 // DetailedStats detailed statistics response
 type DetailedStats struct {
-	Code           string             `json:"code"`
-	OriginalURL    string             `json:"original_url"`
-	TotalClicks    int64              `json:"total_clicks"`
-	UniqueIPs      int64              `json:"unique_ips"`
-	CreatedAt      time.Time          `json:"created_at"`
-	LastAccessedAt *time.Time         `json:"last_accessed_at,omitempty"`
-	HourlyStats    []HourlyStatItem   `json:"hourly_stats"`
-	LocationStats  []LocationStatItem `json:"location_stats"`
-	RecentAccesses []RecentAccessItem `json:"recent_accesses"`
-}
-
-// HourlyStatItem hourly statistics item
-type HourlyStatItem struct {
-	HourBucket  time.Time `json:"hour_bucket"`
+	Code            string             `json:"code"`
+	OriginalURL     string             `json:"original_url"`
+	TotalClicks     int64              `json:"total_clicks"`
+	UniqueIPs       int64              `json:"unique_ips"`
+	CreatedAt       time.Time          `json:"created_at"`
+	LastAccessedAt  *time.Time         `json:"last_accessed_at,omitempty"`
+	RedirectType    int                `json:"redirect_type"`
+	MaxClicks       *int64             `json:"max_clicks,omitempty"`
+	RemainingClicks *int64             `json:"remaining_clicks,omitempty"`
+	FallbackCount   int64              `json:"fallback_count"`
+	BotClicks       int64              `json:"bot_clicks"`                // Not included in the other figures except the client breakdowns and recent accesses
+	InternalClicks  int64              `json:"internal_clicks,omitempty"` // Accesses left out by exclude_internal
+	Granularity     string             `json:"granularity"`
+	Timezone        string             `json:"timezone"`
+	TimeSeries      []TimeSeriesItem   `json:"time_series"` // Oldest first, buckets without clicks included
+	LocationStats   []LocationStatItem `json:"location_stats"`
+	BrowserStats    []BreakdownItem    `json:"browser_stats"`
+	OSStats         []BreakdownItem    `json:"os_stats"`
+	DeviceStats     []BreakdownItem    `json:"device_stats"`
+	ReferrerGroups  []BreakdownItem    `json:"referrer_groups"`
+	ReferrerStats   []ReferrerStatItem `json:"referrer_stats"`
+	CampaignStats   []CampaignStatItem `json:"campaign_stats"`
+	RecentAccesses  []RecentAccessItem `json:"recent_accesses"`
+}
+
+// ReferrerStatItem clicks from one referring site
+type ReferrerStatItem struct {
+	Source      string `json:"source"` // Search engine or social network name, the host of other sites, or "direct"
+	Group       string `json:"group"`  // search, social, other or direct
+	AccessCount int64  `json:"access_count"`
+}
+
+// CampaignStatItem clicks of one UTM source, medium and campaign combination
+type CampaignStatItem struct {
+	Source      string `json:"utm_source"`
+	Medium      string `json:"utm_medium"`
+	Campaign    string `json:"utm_campaign"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// BreakdownItem clicks of one browser, operating system, device class or referrer group
+type BreakdownItem struct {
+	Name        string `json:"name"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// TimeSeriesItem clicks in one time series bucket
+type TimeSeriesItem struct {
+	Bucket      time.Time `json:"bucket"` // Start of the bucket, in the requested time zone
 	AccessCount int64     `json:"access_count"`
+	BotCount    int64     `json:"bot_count"`
 	UniqueIPs   int64     `json:"unique_ips"`
 }
 
@@ -128,4 +489,33 @@ type RecentAccessItem struct {
 	City       string    `json:"city"`
 	AccessTime time.Time `json:"access_time"`
 	UserAgent  string    `json:"user_agent"`
+	IsBot      bool      `json:"is_bot"`
+	Referrer   string    `json:"referrer"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	Device     string    `json:"device"`
+}
+
+// ClickExportRow one click of a click log export, with the location and IP class of its access statistics
+type ClickExportRow struct {
+	ID             uint      `json:"-"` // Export cursor
+	Time           time.Time `json:"time"`
+	IPAddress      string    `json:"ip_address"`
+	IPClass        string    `json:"ip_class"`
+	Country        string    `json:"country"`
+	Region         string    `json:"region"`
+	City           string    `json:"city"`
+	IsBot          bool      `json:"is_bot"`
+	UserAgent      string    `json:"user_agent"`
+	Browser        string    `json:"browser"`
+	BrowserVersion string    `json:"browser_version"`
+	OS             string    `json:"os"`
+	OSVersion      string    `json:"os_version"`
+	Device         string    `json:"device"`
+	Referer        string    `json:"referer"`
+	ReferrerSource string    `json:"referrer_source"`
+	ReferrerGroup  string    `json:"referrer_group"`
+	UTMSource      string    `json:"utm_source"`
+	UTMMedium      string    `json:"utm_medium"`
+	UTMCampaign    string    `json:"utm_campaign"`
 }
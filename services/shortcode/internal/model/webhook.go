@@ -0,0 +1,198 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook event types
+const (
+	EventLinkCreated = "link.created"
+	EventLinkUpdated = "link.updated"
+	EventLinkDeleted = "link.deleted"
+	EventLinkExpired = "link.expired"
+	EventLinkClicked = "link.clicked" // Batch of clicks
+	EventAll         = "*"            // Subscribes a webhook to every event type
+)
+
+// WebhookEventTypes event types webhooks can subscribe to
+var WebhookEventTypes = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkExpired, EventLinkClicked}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending" // Waiting for its first attempt or a retry
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead" // Every attempt failed, a copy is kept in the dead letters
+)
+
+// Webhook endpoint notified of short link events. Webhooks of an API key receive the events
+// of the links the key owns, global webhooks the events of all links.
+type Webhook struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	OwnerID     *uint          `gorm:"index" json:"owner_id,omitempty"` // API key that registered the webhook, nil for global webhooks
+	URL         string         `gorm:"type:text;not null" json:"url"`
+	Events      string         `gorm:"type:text;not null" json:"-"` // Comma separated event types, * for all
+	Secret      string         `gorm:"size:64;not null" json:"-"`   // HMAC-SHA256 signing key, stored in plain text to sign payloads
+	Description string         `gorm:"size:200" json:"description,omitempty"`
+	Disabled    bool           `gorm:"default:false;not null" json:"disabled"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specify table name
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// WebhookDelivery delivery of one event to one webhook, the rows also form the delivery log
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"index;not null" json:"webhook_id"`
+	EventID        string     `gorm:"size:32;index;not null" json:"event_id"` // Shared by the deliveries of one event to several webhooks
+	EventType      string     `gorm:"size:32;not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"-"` // JSON request body
+	Status         string     `gorm:"size:16;not null;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"default:0;not null" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt, 0 without response
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// TableName specify table name
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookDeadLetter delivery that failed on every attempt, kept until it is replayed or too old
+type WebhookDeadLetter struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	DeliveryID     uint      `gorm:"uniqueIndex;not null" json:"delivery_id"`
+	WebhookID      uint      `gorm:"index;not null" json:"webhook_id"`
+	EventID        string    `gorm:"size:32;not null" json:"event_id"`
+	EventType      string    `gorm:"size:32;not null" json:"event_type"`
+	Payload        string    `gorm:"type:text;not null" json:"-"`
+	Attempts       int       `json:"attempts"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	LastError      string    `gorm:"type:text" json:"last_error,omitempty"`
+	FailedAt       time.Time `gorm:"index;not null" json:"failed_at"`
+}
+
+// TableName specify table name
+func (WebhookDeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
+
+// CreateWebhookRequest register webhook request
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,max=2000"`
+	Events      []string `json:"events,omitempty"` // Event types, empty or * for all
+	Global      bool     `json:"global,omitempty"` // Receive the events of all links, admin keys only
+	Description string   `json:"description,omitempty" binding:"max=200"`
+}
+
+// UpdateWebhookRequest update webhook request, only fields that are set are changed
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" binding:"omitempty,max=2000"`
+	Events      []string `json:"events,omitempty"`
+	Description *string  `json:"description,omitempty" binding:"omitempty,max=200"`
+	Disabled    *bool    `json:"disabled,omitempty"`
+}
+
+// WebhookInfo webhook details
+type WebhookInfo struct {
+	ID          uint      `json:"id"`
+	OwnerID     *uint     `json:"owner_id,omitempty"`
+	Global      bool      `json:"global"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Disabled    bool      `json:"disabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateWebhookResponse register webhook response, the signing secret is only returned once
+type CreateWebhookResponse struct {
+	WebhookInfo
+	Secret string `json:"secret"`
+}
+
+// ListWebhookDeliveriesRequest delivery log query, newest first
+type ListWebhookDeliveriesRequest struct {
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Status    string `form:"status" binding:"omitempty,oneof=pending succeeded dead"`
+	EventType string `form:"event_type" binding:"omitempty,max=32"`
+}
+
+// WebhookDeliveryFilter repository filter of the delivery log
+type WebhookDeliveryFilter struct {
+	WebhookID uint
+	Status    string
+	EventType string
+	BeforeID  uint // Keyset pagination, 0 for the first page
+	Limit     int
+}
+
+// WebhookDeliveryInfo delivery log entry with the payload that was sent
+type WebhookDeliveryInfo struct {
+	WebhookDelivery
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ListWebhookDeliveriesResponse delivery log page
+type ListWebhookDeliveriesResponse struct {
+	Items      []WebhookDeliveryInfo `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	HasMore    bool                  `json:"has_more"`
+}
+
+// ListWebhookDeadLettersRequest dead letter query, newest first
+type ListWebhookDeadLettersRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// WebhookDeadLetterInfo dead letter with the payload that could not be delivered
+type WebhookDeadLetterInfo struct {
+	WebhookDeadLetter
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ListWebhookDeadLettersResponse dead letter page
+type ListWebhookDeadLettersResponse struct {
+	Items      []WebhookDeadLetterInfo `json:"items"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	HasMore    bool                    `json:"has_more"`
+}
+
+// WebhookEvent body of a webhook request
+type WebhookEvent struct {
+	ID        string      `json:"id"` // Same on every retry, receivers use it to skip duplicates
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"` // WebhookLinkData or WebhookClickData
+	OwnerID   *uint       `json:"-"`    // Owner of the link, selects the webhooks of the event
+}
+
+// LinkEvent link lifecycle event waiting to be queued for the webhooks
+type LinkEvent struct {
+	Type string
+	Link *ShortCodeInfo
+}
+
+// WebhookLinkData data of link lifecycle events
+type WebhookLinkData struct {
+	Link *ShortCodeInfo `json:"link"`
+}
+
+// WebhookClickData data of link.clicked events
+type WebhookClickData struct {
+	Clicks []RecordedClick `json:"clicks"`
+}
@@ -0,0 +1,111 @@
+package referrer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// maxFieldLength longest stored source or UTM value, longer values are truncated
+const maxFieldLength = 100
+
+// Info normalized referrer of a click
+type Info struct {
+	Source      string // Search engine or social network name, the host (without "www.") of other sites, or "direct"
+	Group       string // One of the model.Referrer* groups
+	UTMSource   string
+	UTMMedium   string
+	UTMCampaign string
+}
+
+// site maps a host pattern to the name and group of a well-known referrer
+type site struct {
+	name  string
+	group string
+	re    *regexp.Regexp
+}
+
+// sites search engines and social networks, hosts are matched including subdomains
+var sites = []site{
+	{"Google", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)google\.(?:[a-z]{2,3}|co\.[a-z]{2}|com\.[a-z]{2})$`)},
+	{"Bing", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)bing\.com$`)},
+	{"Yahoo", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)search\.yahoo\.(?:com|co\.jp)$`)},
+	{"DuckDuckGo", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)duckduckgo\.com$`)},
+	{"Baidu", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)baidu\.com$`)},
+	{"Yandex", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)yandex\.(?:ru|com|com\.tr|by|kz)$|(?:^|\.)ya\.ru$`)},
+	{"Ecosia", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)ecosia\.org$`)},
+	{"Brave Search", model.ReferrerSearch, regexp.MustCompile(`^search\.brave\.com$`)},
+	{"Naver", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)search\.naver\.com$`)},
+	{"Sogou", model.ReferrerSearch, regexp.MustCompile(`(?:^|\.)sogou\.com$`)},
+	{"Facebook", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)(?:facebook\.com|fb\.com|fb\.me)$`)},
+	{"Instagram", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)instagram\.com$`)},
+	{"X (Twitter)", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)(?:twitter\.com|x\.com)$|^t\.co$`)},
+	{"LinkedIn", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)linkedin\.com$|^lnkd\.in$`)},
+	{"Reddit", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)reddit\.com$|^redd\.it$`)},
+	{"YouTube", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)youtube\.com$|^youtu\.be$`)},
+	{"TikTok", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)tiktok\.com$`)},
+	{"Pinterest", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)pinterest\.(?:com|[a-z]{2})$|^pin\.it$`)},
+	{"Hacker News", model.ReferrerSocial, regexp.MustCompile(`^news\.ycombinator\.com$`)},
+	{"Mastodon", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)(?:mastodon\.social|mastodon\.online)$`)},
+	{"Bluesky", model.ReferrerSocial, regexp.MustCompile(`^bsky\.app$`)},
+	{"Telegram", model.ReferrerSocial, regexp.MustCompile(`^(?:t\.me|web\.telegram\.org)$`)},
+	{"WhatsApp", model.ReferrerSocial, regexp.MustCompile(`^(?:wa\.me|web\.whatsapp\.com)$`)},
+	{"Discord", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)discord(?:app)?\.com$`)},
+	{"Slack", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)slack\.com$`)},
+	{"WeChat", model.ReferrerSocial, regexp.MustCompile(`^(?:mp\.)?weixin\.qq\.com$`)},
+	{"Weibo", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)weibo\.(?:com|cn)$`)},
+	{"Zhihu", model.ReferrerSocial, regexp.MustCompile(`(?:^|\.)zhihu\.com$`)},
+}
+
+// Parse normalizes a Referer header. Clicks without a referrer are "direct", app referrers
+// such as android-app://com.slack keep the app package as their source.
+func Parse(raw string) Info {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Info{Source: model.ReferrerDirect, Group: model.ReferrerDirect}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return Info{Source: "Unknown", Group: model.ReferrerOther}
+	}
+
+	host := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), "www.")
+	info := Info{
+		Source:      host,
+		Group:       model.ReferrerOther,
+		UTMSource:   u.Query().Get("utm_source"),
+		UTMMedium:   u.Query().Get("utm_medium"),
+		UTMCampaign: u.Query().Get("utm_campaign"),
+	}
+
+	for _, s := range sites {
+		if s.re.MatchString(host) {
+			info.Source = s.name
+			info.Group = s.group
+			break
+		}
+	}
+
+	info.Source = truncate(info.Source)
+	info.UTMSource = truncate(strings.TrimSpace(info.UTMSource))
+	info.UTMMedium = truncate(strings.TrimSpace(info.UTMMedium))
+	info.UTMCampaign = truncate(strings.TrimSpace(info.UTMCampaign))
+	return info
+}
+
+// truncate shortens a value to maxFieldLength bytes without splitting a UTF-8 character
+func truncate(s string) string {
+	if len(s) <= maxFieldLength {
+		return s
+	}
+
+	s = s[:maxFieldLength]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
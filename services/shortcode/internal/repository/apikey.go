@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// APIKeyRepository API key repository interface
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	FindByHashUnscoped(ctx context.Context, keyHash string) (*model.APIKey, error)
+	TouchLastUsed(ctx context.Context, id uint) error
+	Revoke(ctx context.Context, id uint) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository create API key repository instance
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+// Create create API key
+func (r *apiKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// GetByHash get API key by the hash of the key
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.WithContext(ctx).
+		Where("key_hash = ?", keyHash).
+		First(&key).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// FindByHashUnscoped find API key by the hash of the key, including revoked keys. Returns nil if no key has the hash.
+func (r *apiKeyRepository) FindByHashUnscoped(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	// Unscoped: revoked keys still hold their hash in the unique index
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("key_hash = ?", keyHash).
+		First(&key).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// TouchLastUsed update the last used time of an API key
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", time.Now()).Error
+}
+
+// Revoke revoke API key
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.APIKey{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("api key not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// Redis pub/sub channels of recorded clicks, one per short code and one per owner
+const (
+	liveCodeChannel  = "shortcode:live:code:%s"
+	liveOwnerChannel = "shortcode:live:owner:%d"
+)
+
+// liveBufferSize clicks waiting for a slow subscriber before further clicks are dropped for it
+const liveBufferSize = 256
+
+// ErrLiveStreamClosed the live click stream was closed, e.g. because the server is shutting down
+var ErrLiveStreamClosed = errors.New("live click stream closed")
+
+// LiveClickRepository live click stream. Recorded clicks are published on Redis pub/sub, so every
+// replica receives them, and fanned out to the local subscribers of each replica.
+type LiveClickRepository interface {
+	Publish(ctx context.Context, clicks []model.RecordedClick) error
+	SubscribeCode(ctx context.Context, code string) (*LiveSubscription, error)
+	SubscribeOwner(ctx context.Context, ownerID uint) (*LiveSubscription, error)
+	Close() error
+}
+
+// LiveSubscription clicks of one short code or owner, received until Close. The channel is closed
+// when the subscription or the stream is closed.
+type LiveSubscription struct {
+	C <-chan model.RecordedClick
+
+	clicks  chan model.RecordedClick
+	channel string
+	repo    *liveClickRepository
+	once    sync.Once
+}
+
+// Close stops the subscription
+func (s *LiveSubscription) Close() {
+	s.once.Do(func() {
+		s.repo.unsubscribe(s)
+	})
+}
+
+type liveClickRepository struct {
+	redisClient *redis.Client
+
+	mu          sync.Mutex // Guards the subscribers against the receive loop
+	pubsub      *redis.PubSub
+	subscribers map[string]map[*LiveSubscription]struct{}
+	closed      bool
+}
+
+// NewLiveClickRepository create live click stream instance, the Redis subscription is opened by the first subscriber
+func NewLiveClickRepository(redisClient *redis.Client) LiveClickRepository {
+	return &liveClickRepository{
+		redisClient: redisClient,
+		subscribers: make(map[string]map[*LiveSubscription]struct{}),
+	}
+}
+
+// Publish publishes clicks on the channel of their short code and of their owner, in one round trip
+func (r *liveClickRepository) Publish(ctx context.Context, clicks []model.RecordedClick) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	pipe := r.redisClient.Pipeline()
+	for _, click := range clicks {
+		data, err := json.Marshal(click)
+		if err != nil {
+			return err
+		}
+		pipe.Publish(ctx, fmt.Sprintf(liveCodeChannel, click.Code), data)
+		if click.OwnerID != nil {
+			pipe.Publish(ctx, fmt.Sprintf(liveOwnerChannel, *click.OwnerID), data)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SubscribeCode subscribes to the clicks of a short code
+func (r *liveClickRepository) SubscribeCode(ctx context.Context, code string) (*LiveSubscription, error) {
+	return r.subscribe(ctx, fmt.Sprintf(liveCodeChannel, code))
+}
+
+// SubscribeOwner subscribes to the clicks of all short links of an API key
+func (r *liveClickRepository) SubscribeOwner(ctx context.Context, ownerID uint) (*LiveSubscription, error) {
+	return r.subscribe(ctx, fmt.Sprintf(liveOwnerChannel, ownerID))
+}
+
+// Close ends every subscription and closes the Redis subscription
+func (r *liveClickRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	for _, subscribers := range r.subscribers {
+		for subscription := range subscribers {
+			close(subscription.clicks)
+		}
+	}
+	r.subscribers = nil
+
+	if r.pubsub == nil {
+		return nil
+	}
+	return r.pubsub.Close()
+}
+
+// subscribe registers a local subscriber, the Redis channel is subscribed by its first local subscriber
+func (r *liveClickRepository) subscribe(ctx context.Context, channel string) (*LiveSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrLiveStreamClosed
+	}
+
+	subscribers, ok := r.subscribers[channel]
+	if !ok {
+		if r.pubsub == nil {
+			// One connection per replica serves all subscribers, it resubscribes after reconnecting
+			r.pubsub = r.redisClient.Subscribe(ctx, channel)
+			go r.receive(r.pubsub.Channel())
+		} else if err := r.pubsub.Subscribe(ctx, channel); err != nil {
+			return nil, err
+		}
+		subscribers = make(map[*LiveSubscription]struct{})
+		r.subscribers[channel] = subscribers
+	}
+
+	clicks := make(chan model.RecordedClick, liveBufferSize)
+	subscription := &LiveSubscription{
+		C:       clicks,
+		clicks:  clicks,
+		channel: channel,
+		repo:    r,
+	}
+	subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+// unsubscribe removes a local subscriber, the Redis channel is unsubscribed with its last local subscriber
+func (r *liveClickRepository) unsubscribe(subscription *LiveSubscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscribers, ok := r.subscribers[subscription.channel]
+	if !ok {
+		return // Closed with the stream
+	}
+	if _, ok := subscribers[subscription]; !ok {
+		return
+	}
+	delete(subscribers, subscription)
+	close(subscription.clicks)
+
+	if len(subscribers) == 0 {
+		delete(r.subscribers, subscription.channel)
+		if err := r.pubsub.Unsubscribe(context.Background(), subscription.channel); err != nil {
+			slog.Warn("Failed to unsubscribe live click channel", "channel", subscription.channel, "error", err)
+		}
+	}
+}
+
+// receive hands the messages of the Redis subscription to the local subscribers until it is closed
+func (r *liveClickRepository) receive(messages <-chan *redis.Message) {
+	for message := range messages {
+		var click model.RecordedClick
+		if err := json.Unmarshal([]byte(message.Payload), &click); err != nil {
+			slog.Warn("Skipping malformed live click", "channel", message.Channel, "error", err)
+			continue
+		}
+
+		r.mu.Lock()
+		for subscription := range r.subscribers[message.Channel] {
+			select {
+			case subscription.clicks <- click:
+			default: // The subscriber is behind, it misses this click rather than holding up the others
+			}
+		}
+		r.mu.Unlock()
+	}
+}
@@ -1,51 +1,115 @@
 package repository
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
 	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
 	"github.com/lincyaw/tools/services/shortcode/internal/model"
 )
 
+var (
+	// ErrNotYetActive the short link exists but its activation time has not come yet
+	ErrNotYetActive = errors.New("short code not yet active")
+	// ErrExpired the short link exists but has expired
+	ErrExpired = errors.New("short code expired")
+	// ErrDisabled the short link exists but was disabled by its owner
+	ErrDisabled = errors.New("short code disabled")
+)
+
 // ShortCodeRepository short link repository interface
 type ShortCodeRepository interface {
 	Create(ctx context.Context, shortCode *model.ShortCode) error
+	CreateBatch(ctx context.Context, shortCodes []*model.ShortCode) error
 	GetByCode(ctx context.Context, code string) (*model.ShortCode, error)
-	UpdateClickCount(ctx context.Context, id uint) error
+	FindByCode(ctx context.Context, code string) (*model.ShortCode, error)
+	List(ctx context.Context, filter *model.ShortCodeFilter) ([]model.ShortCode, error)
+	UpdateShortCode(ctx context.Context, code string, updates map[string]interface{}) (*model.ShortCode, error)
+	FindRefsByCodes(ctx context.Context, codes []string) (map[string]model.ShortCodeRef, error)
+	ClaimClick(ctx context.Context, id uint) (claimed bool, exhausted bool, err error)
+	SaveClicks(ctx context.Context, batch *model.ClickBatch) error
+	ProcessedEventIDs(ctx context.Context, ids []string) (map[string]bool, error)
+	PruneProcessedEvents(ctx context.Context, before time.Time) (int64, error)
 	GetStats(ctx context.Context, code string) (*model.ShortCodeStats, error)
-	LogClick(ctx context.Context, log *model.ClickLog) error
 	CodeExists(ctx context.Context, code string) (bool, error)
+	ExistingCodes(ctx context.Context, codes []string) ([]string, error)
 	Delete(ctx context.Context, code string) error
 	InvalidateCache(ctx context.Context, code string) error
-	GetMetrics(ctx context.Context) (map[string]interface{}, error)
+	InvalidateCaches(ctx context.Context, codes []string) error
+	ListExpired(ctx context.Context, now time.Time, after *model.ExpiryCursor, limit int) ([]model.ShortCode, error)
+	ClaimExpired(ctx context.Context, ids []uint, now time.Time) ([]uint, error)
+	ClaimExpiryReport(ctx context.Context, ids []uint, now time.Time) ([]uint, error)
+	DeleteByIDs(ctx context.Context, ids []uint) error
+	Archive(ctx context.Context, shortCodes []model.ShortCode) error
+	GetMetrics(ctx context.Context) (*model.MetricsSummary, error)
 	RecordAccessStats(ctx context.Context, stats *model.AccessStatistics) error
-	GetDetailedStats(ctx context.Context, code string, hours int) (*model.DetailedStats, error)
+	GetDetailedStats(ctx context.Context, code string, query *model.StatsQuery) (*model.DetailedStats, error)
+	GetTimeSeries(ctx context.Context, code string, query *model.StatsQuery) ([]model.TimeSeriesItem, error)
+	ExportClickLogs(ctx context.Context, code string, query *model.StatsQuery, batchSize int, fn func([]model.ClickExportRow) error) error
 }
 
+// maxCacheTTL cache lifetime of short links without a nearer expiry
+const maxCacheTTL = 24 * time.Hour
+
+// tracer creates the spans of the repository layer, queries and Redis commands get their own child spans
+var tracer = otel.Tracer("github.com/lincyaw/tools/services/shortcode/internal/repository")
+
+// metricsCacheKey and metricsCacheTTL cache of the metrics summary, shared by all instances
+const (
+	metricsCacheKey = "metrics:summary"
+	metricsCacheTTL = time.Minute
+)
+
 type shortCodeRepository struct {
 	db          *gorm.DB
 	redisClient *redis.Client
 }
 
+// cachedShortCode cache entry of a short link, keeps the fields hidden from the JSON API
+type cachedShortCode struct {
+	model.ShortCode
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// gormLogLevels GORM log levels by DB_LOG_LEVEL name
+var gormLogLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
 // NewPostgresDB create PostgreSQL database connection
 func NewPostgresDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName)
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			LogLevel:                  gormLogLevels[cfg.LogLevel],
+			SlowThreshold:             cfg.SlowQueryThreshold,
+			ParameterizedQueries:      true, // Bound values such as IPs and password hashes stay out of the logs
+			IgnoreRecordNotFoundError: true,
+		}),
 		NowFunc: func() time.Time {
-			return time.Now().Local()
+			return time.Now().UTC()
 		},
 	})
 	if err != nil {
@@ -63,10 +127,36 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(5)
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)
 
+	// Rows counted twice by concurrent writers before the unique key existed are merged, so the index can be built
+	if db.Migrator().HasTable(&model.AccessStatistics{}) && !db.Migrator().HasIndex(&model.AccessStatistics{}, "idx_access_statistics_key") {
+		// Databases from before bot detection have no bot flag yet, all their rows become human accesses
+		keyColumns := "short_code_id, ip_address, hour_bucket"
+		if db.Migrator().HasColumn(&model.AccessStatistics{}, "IsBot") {
+			keyColumns += ", is_bot"
+		}
+		if err := mergeDuplicateAccessStats(db, keyColumns); err != nil {
+			return nil, fmt.Errorf("failed to merge duplicate access statistics: %w", err)
+		}
+	}
+
+	// Expiries the sweeper handled before redirects reported them count as reported, set once when the column is added
+	backfillExpiryReports := !db.Migrator().HasColumn(&model.ShortCode{}, "ExpiryReportedAt")
+
 	// Auto migrate
-	if err := db.AutoMigrate(&model.ShortCode{}, &model.ClickLog{}, &model.AccessStatistics{}); err != nil {
+	if err := db.AutoMigrate(&model.ShortCode{}, &model.ClickLog{}, &model.AccessStatistics{}, &model.APIKey{}, &model.ArchivedShortCode{}, &model.ProcessedClickEvent{},
+		&model.Webhook{}, &model.WebhookDelivery{}, &model.WebhookDeadLetter{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if backfillExpiryReports {
+		if err := db.Exec("UPDATE short_codes SET expiry_reported_at = expiry_handled_at WHERE expiry_handled_at IS NOT NULL").Error; err != nil {
+			return nil, fmt.Errorf("failed to migrate expiry reports: %w", err)
+		}
+	}
+
+	// Trace queries from here on, without their bound values (IPs, password hashes)
+	if err := db.Use(queryTracer{}); err != nil {
+		return nil, fmt.Errorf("failed to enable query tracing: %w", err)
+	}
 
 	return db, nil
 }
@@ -78,6 +168,11 @@ func NewRedisClient(cfg config.RedisConfig) *redis.Client {
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
+
+	// Trace commands without their arguments, cached links contain password hashes
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		slog.Warn("Failed to enable Redis tracing", "error", err)
+	}
 	return client
 }
 
@@ -94,23 +189,38 @@ func (r *shortCodeRepository) Create(ctx context.Context, shortCode *model.Short
 	return r.db.WithContext(ctx).Create(shortCode).Error
 }
 
-// GetByCode get short link by code
+// CreateBatch create short links in a single transaction
+func (r *shortCodeRepository) CreateBatch(ctx context.Context, shortCodes []*model.ShortCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(shortCodes, 500).Error
+	})
+}
+
+// GetByCode get a live short link by code.
+// Links that are not live are returned together with ErrNotYetActive, ErrExpired or ErrDisabled.
 func (r *shortCodeRepository) GetByCode(ctx context.Context, code string) (*model.ShortCode, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.GetByCode")
+	defer span.End()
+
 	// First try to get from cache
 	cacheKey := fmt.Sprintf("shortcode:%s", code)
 	cached, err := r.redisClient.Get(ctx, cacheKey).Result()
 	if err == nil {
-		var shortCode model.ShortCode
-		if err := json.Unmarshal([]byte(cached), &shortCode); err == nil {
-			return &shortCode, nil
+		var entry cachedShortCode
+		if err := json.Unmarshal([]byte(cached), &entry); err == nil {
+			entry.ShortCode.PasswordHash = entry.PasswordHash
+			metrics.RedirectCacheLookups.WithLabelValues("hit").Inc()
+			span.SetAttributes(attribute.Bool("shortcode.cache_hit", true))
+			return &entry.ShortCode, checkLive(&entry.ShortCode, time.Now())
 		}
 	}
+	metrics.RedirectCacheLookups.WithLabelValues("miss").Inc()
+	span.SetAttributes(attribute.Bool("shortcode.cache_hit", false))
 
-	// Get from database
+	// Get from database, the schedule is checked on every read so cached rows are never stale
 	var shortCode model.ShortCode
 	err = r.db.WithContext(ctx).
 		Where("code = ?", code).
-		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
 		First(&shortCode).Error
 
 	if err != nil {
@@ -120,28 +230,201 @@ func (r *shortCodeRepository) GetByCode(ctx context.Context, code string) (*mode
 		return nil, err
 	}
 
-	// Cache to Redis (24 hours)
-	if data, err := json.Marshal(shortCode); err == nil {
-		r.redisClient.Set(ctx, cacheKey, data, 24*time.Hour)
+	// Cache to Redis, never beyond the expiry of the link
+	now := time.Now()
+	if ttl := cacheTTL(&shortCode, now); ttl > 0 {
+		entry := cachedShortCode{ShortCode: shortCode, PasswordHash: shortCode.PasswordHash}
+		if data, err := json.Marshal(entry); err == nil {
+			r.redisClient.Set(ctx, cacheKey, data, ttl)
+		}
+	}
+
+	return &shortCode, checkLive(&shortCode, now)
+}
+
+// cacheTTL returns how long a short link may be cached, 0 for links that are already expired
+func cacheTTL(shortCode *model.ShortCode, now time.Time) time.Duration {
+	ttl := maxCacheTTL
+	if shortCode.ExpiresAt != nil {
+		ttl = min(ttl, shortCode.ExpiresAt.Sub(now))
+	}
+	return max(ttl, 0)
+}
+
+// checkLive reports whether the short link is live at the given time
+func checkLive(shortCode *model.ShortCode, now time.Time) error {
+	if shortCode.Disabled {
+		return ErrDisabled
+	}
+	if shortCode.ActivatesAt != nil && now.Before(*shortCode.ActivatesAt) {
+		return ErrNotYetActive
+	}
+	if shortCode.ExpiresAt != nil && !now.Before(*shortCode.ExpiresAt) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// FindByCode get short link by code, including expired ones, bypassing the cache
+func (r *shortCodeRepository) FindByCode(ctx context.Context, code string) (*model.ShortCode, error) {
+	var shortCode model.ShortCode
+	err := r.db.WithContext(ctx).
+		Where("code = ?", code).
+		First(&shortCode).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("short code not found")
+		}
+		return nil, err
 	}
 
 	return &shortCode, nil
 }
 
-// UpdateClickCount update click count
-func (r *shortCodeRepository) UpdateClickCount(ctx context.Context, id uint) error {
+// List list short links matching the filter, ordered for keyset pagination
+func (r *shortCodeRepository) List(ctx context.Context, filter *model.ShortCodeFilter) ([]model.ShortCode, error) {
+	query := r.db.WithContext(ctx).Model(&model.ShortCode{})
+
+	if filter.OwnerID != nil {
+		query = query.Where("owner_id = ?", *filter.OwnerID)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+
 	now := time.Now()
-	return r.db.WithContext(ctx).
+	switch filter.Status {
+	case "active":
+		query = query.Where("disabled = ?", false).
+			Where("activates_at IS NULL OR activates_at <= ?", now).
+			Where("expires_at IS NULL OR expires_at > ?", now)
+	case "scheduled":
+		query = query.Where("activates_at > ?", now)
+	case "expired":
+		query = query.Where("expires_at <= ?", now)
+	}
+
+	if filter.HasClicks != nil {
+		if *filter.HasClicks {
+			query = query.Where("click_count > 0")
+		} else {
+			query = query.Where("click_count = 0")
+		}
+	}
+
+	if filter.URLContains != "" {
+		query = query.Where("original_url ILIKE ?", "%"+escapeLike(filter.URLContains)+"%")
+	}
+
+	// Sort column is whitelisted, the cursor compares (column, id) as a row value
+	column := "created_at"
+	if filter.SortBy == "click_count" {
+		column = "click_count"
+	}
+	direction, operator := "DESC", "<"
+	if filter.Ascending {
+		direction, operator = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		var value interface{} = filter.After.CreatedAt
+		if column == "click_count" {
+			value = filter.After.ClickCount
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, operator), value, filter.After.ID)
+	}
+
+	var shortCodes []model.ShortCode
+	err := query.
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(filter.Limit).
+		Find(&shortCodes).Error
+
+	return shortCodes, err
+}
+
+// UpdateShortCode update mutable fields of a short link and invalidate its cache
+func (r *shortCodeRepository) UpdateShortCode(ctx context.Context, code string, updates map[string]interface{}) (*model.ShortCode, error) {
+	result := r.db.WithContext(ctx).
 		Model(&model.ShortCode{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"click_count":      gorm.Expr("click_count + ?", 1),
-			"last_accessed_at": now,
-		}).Error
+		Where("code = ?", code).
+		Updates(updates)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("short code not found")
+	}
+
+	// Invalidate after the write so redirects pick up the change immediately
+	if err := r.InvalidateCache(ctx, code); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate cache", "error", err)
+	}
+
+	return r.FindByCode(ctx, code)
+}
+
+// FindRefsByCodes map codes to short link IDs and owners, unknown codes are left out
+func (r *shortCodeRepository) FindRefsByCodes(ctx context.Context, codes []string) (map[string]model.ShortCodeRef, error) {
+	refs := make(map[string]model.ShortCodeRef, len(codes))
+	if len(codes) == 0 {
+		return refs, nil
+	}
+
+	var rows []struct {
+		ID      uint
+		Code    string
+		OwnerID *uint
+	}
+	err := r.db.WithContext(ctx).
+		Model(&model.ShortCode{}).
+		Select("id, code, owner_id").
+		Where("code IN ?", codes).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		refs[row.Code] = model.ShortCodeRef{ID: row.ID, OwnerID: row.OwnerID}
+	}
+	return refs, nil
+}
+
+// ClaimClick atomically counts a click on a click-limited link, reports false once the limit is reached.
+// exhausted reports that this click was the last one allowed, so exactly one redirect sees it.
+func (r *shortCodeRepository) ClaimClick(ctx context.Context, id uint) (bool, bool, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.ClaimClick")
+	defer span.End()
+
+	// The condition is checked by the database, so concurrent redirects cannot overshoot the limit
+	now := time.Now()
+	var rows []struct{ Exhausted bool }
+	err := r.db.WithContext(ctx).Raw(`UPDATE short_codes SET click_count = click_count + 1, last_accessed_at = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND (max_clicks IS NULL OR click_count < max_clicks)
+		RETURNING (max_clicks IS NOT NULL AND click_count >= max_clicks) AS exhausted`, now, now, id).
+		Scan(&rows).Error
+
+	if err != nil {
+		return false, false, err
+	}
+	if len(rows) == 0 {
+		return false, false, nil
+	}
+	return true, rows[0].Exhausted, nil
 }
 
 // GetStats get statistics
 func (r *shortCodeRepository) GetStats(ctx context.Context, code string) (*model.ShortCodeStats, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.GetStats")
+	defer span.End()
+
 	var shortCode model.ShortCode
 	err := r.db.WithContext(ctx).
 		Where("code = ?", code).
@@ -155,25 +438,28 @@ func (r *shortCodeRepository) GetStats(ctx context.Context, code string) (*model
 	}
 
 	stats := &model.ShortCodeStats{
-		Code:           shortCode.Code,
-		OriginalURL:    shortCode.OriginalURL,
-		ClickCount:     shortCode.ClickCount,
-		CreatedAt:      shortCode.CreatedAt,
-		LastAccessedAt: shortCode.LastAccessedAt,
+		Code:              shortCode.Code,
+		OriginalURL:       shortCode.OriginalURL,
+		ClickCount:        shortCode.ClickCount,
+		CreatedAt:         shortCode.CreatedAt,
+		LastAccessedAt:    shortCode.LastAccessedAt,
+		RedirectType:      shortCode.RedirectType,
+		PasswordProtected: shortCode.PasswordHash != "",
+		MaxClicks:         shortCode.MaxClicks,
+		FallbackCount:     shortCode.FallbackCount,
+		Disabled:          shortCode.Disabled,
+		BotClickCount:     shortCode.BotClickCount,
 	}
 
 	return stats, nil
 }
 
-// LogClick log click
-func (r *shortCodeRepository) LogClick(ctx context.Context, log *model.ClickLog) error {
-	return r.db.WithContext(ctx).Create(log).Error
-}
-
 // CodeExists check if code exists
 func (r *shortCodeRepository) CodeExists(ctx context.Context, code string) (bool, error) {
 	var count int64
+	// Unscoped: deleted links still hold their code in the unique index
 	err := r.db.WithContext(ctx).
+		Unscoped().
 		Model(&model.ShortCode{}).
 		Where("code = ?", code).
 		Count(&count).Error
@@ -181,12 +467,29 @@ func (r *shortCodeRepository) CodeExists(ctx context.Context, code string) (bool
 	return count > 0, err
 }
 
+// ExistingCodes return the subset of codes that are already taken
+func (r *shortCodeRepository) ExistingCodes(ctx context.Context, codes []string) ([]string, error) {
+	var existing []string
+	if len(codes) == 0 {
+		return existing, nil
+	}
+
+	// Unscoped: deleted links still hold their code in the unique index
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Model(&model.ShortCode{}).
+		Where("code IN ?", codes).
+		Pluck("code", &existing).Error
+
+	return existing, err
+}
+
 // Delete delete short link
 func (r *shortCodeRepository) Delete(ctx context.Context, code string) error {
 	// Delete cache
 	if err := r.InvalidateCache(ctx, code); err != nil {
 		// Log cache invalidation error but continue with deletion
-		log.Printf("Warning: Failed to invalidate cache for code %s: %v", code, err)
+		slog.WarnContext(ctx, "Failed to invalidate cache", "error", err)
 	}
 
 	// Delete from database
@@ -211,73 +514,302 @@ func (r *shortCodeRepository) InvalidateCache(ctx context.Context, code string)
 	return r.redisClient.Del(ctx, cacheKey).Err()
 }
 
-// GetMetrics get system metrics
-func (r *shortCodeRepository) GetMetrics(ctx context.Context) (map[string]interface{}, error) {
-	metrics := make(map[string]interface{})
+// InvalidateCaches invalidate the cache of several short links in one round trip
+func (r *shortCodeRepository) InvalidateCaches(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
 
-	// Count total short codes
-	var totalCodes int64
-	if err := r.db.WithContext(ctx).Model(&model.ShortCode{}).Count(&totalCodes).Error; err != nil {
-		return nil, err
+	cacheKeys := make([]string, len(codes))
+	for i, code := range codes {
+		cacheKeys[i] = fmt.Sprintf("shortcode:%s", code)
 	}
-	metrics["total_codes"] = totalCodes
+	return r.redisClient.Del(ctx, cacheKeys...).Err()
+}
 
-	// Count total clicks
-	var totalClicks int64
-	if err := r.db.WithContext(ctx).Model(&model.ShortCode{}).Select("COALESCE(SUM(click_count), 0)").Scan(&totalClicks).Error; err != nil {
+// ListExpired list links expired at the given time and not handled since, ordered by expiry and continuing after the cursor
+func (r *shortCodeRepository) ListExpired(ctx context.Context, now time.Time, after *model.ExpiryCursor, limit int) ([]model.ShortCode, error) {
+	query := r.db.WithContext(ctx).
+		Where("expires_at <= ?", now).
+		Where("(expiry_handled_at IS NULL OR expiry_handled_at < expires_at)")
+
+	if after != nil {
+		query = query.Where("(expires_at, id) > (?, ?)", after.ExpiresAt, after.ID)
+	}
+
+	var shortCodes []model.ShortCode
+	err := query.
+		Order("expires_at ASC, id ASC").
+		Limit(limit).
+		Find(&shortCodes).Error
+
+	return shortCodes, err
+}
+
+// ClaimExpired marks expired links as handled and returns the IDs this call marked, links another
+// sweeper handled meanwhile are left out. Links removed by the sweep are included.
+func (r *shortCodeRepository) ClaimExpired(ctx context.Context, ids []uint, now time.Time) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var claimed []uint
+	err := r.db.WithContext(ctx).Raw(`UPDATE short_codes SET expiry_handled_at = ?
+		WHERE id IN ? AND (expiry_handled_at IS NULL OR expiry_handled_at < expires_at)
+		RETURNING id`, now, ids).
+		Scan(&claimed).Error
+	return claimed, err
+}
+
+// ClaimExpiryReport marks the expiry of expired links as reported and returns the IDs this call marked,
+// so link.expired is sent once per expiry whether a redirect or the sweeper notices it first
+func (r *shortCodeRepository) ClaimExpiryReport(ctx context.Context, ids []uint, now time.Time) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var claimed []uint
+	err := r.db.WithContext(ctx).Raw(`UPDATE short_codes SET expiry_reported_at = ?
+		WHERE id IN ? AND expires_at <= ? AND (expiry_reported_at IS NULL OR expiry_reported_at < expires_at)
+		RETURNING id`, now, ids, now).
+		Scan(&claimed).Error
+	return claimed, err
+}
+
+// DeleteByIDs soft delete short links by ID
+func (r *shortCodeRepository) DeleteByIDs(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Delete(&model.ShortCode{}).Error
+}
+
+// Archive copy short links to the archive table and soft delete them, in a single transaction
+func (r *shortCodeRepository) Archive(ctx context.Context, shortCodes []model.ShortCode) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	archived := make([]model.ArchivedShortCode, len(shortCodes))
+	ids := make([]uint, len(shortCodes))
+	for i, shortCode := range shortCodes {
+		archived[i] = model.ArchivedShortCode{
+			ID:             shortCode.ID,
+			Code:           shortCode.Code,
+			OriginalURL:    shortCode.OriginalURL,
+			OwnerID:        shortCode.OwnerID,
+			ClickCount:     shortCode.ClickCount,
+			CreatedAt:      shortCode.CreatedAt,
+			ExpiresAt:      shortCode.ExpiresAt,
+			LastAccessedAt: shortCode.LastAccessedAt,
+			ArchivedAt:     now,
+		}
+		ids[i] = shortCode.ID
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Ignore rows archived by an earlier run that failed before the delete
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&archived).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.ShortCode{}).Error
+	})
+}
+
+// GetMetrics get the business totals. They scan whole tables, so they are computed at most
+// once per metricsCacheTTL and shared through Redis.
+func (r *shortCodeRepository) GetMetrics(ctx context.Context) (*model.MetricsSummary, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.GetMetrics")
+	defer span.End()
+
+	var summary model.MetricsSummary
+	if cached, err := r.redisClient.Get(ctx, metricsCacheKey).Result(); err == nil {
+		if err := json.Unmarshal([]byte(cached), &summary); err == nil {
+			return &summary, nil
+		}
+	}
+
+	// Short link totals in one scan
+	err := r.db.WithContext(ctx).Model(&model.ShortCode{}).
+		Select("COUNT(*) AS total_codes, COALESCE(SUM(click_count), 0) AS total_clicks, " +
+			"COUNT(*) FILTER (WHERE click_count > 0) AS active_codes").
+		Scan(&summary).Error
+	if err != nil {
 		return nil, err
 	}
-	metrics["total_clicks"] = totalClicks
 
-	// Count clicks in the past 24 hours
-	var clicks24h int64
+	// Count human clicks in the past 24 hours, like click_count
 	oneDayAgo := time.Now().Add(-24 * time.Hour)
 	if err := r.db.WithContext(ctx).Model(&model.ClickLog{}).
-		Where("created_at > ?", oneDayAgo).
-		Count(&clicks24h).Error; err != nil {
+		Where("created_at > ? AND is_bot = ?", oneDayAgo, false).
+		Count(&summary.Clicks24h).Error; err != nil {
 		return nil, err
 	}
-	metrics["clicks_24h"] = clicks24h
 
-	// Count active short codes (with clicks)
-	var activeCodes int64
-	if err := r.db.WithContext(ctx).Model(&model.ShortCode{}).
-		Where("click_count > 0").
-		Count(&activeCodes).Error; err != nil {
+	summary.GeneratedAt = time.Now()
+	if data, err := json.Marshal(summary); err == nil {
+		r.redisClient.Set(ctx, metricsCacheKey, data, metricsCacheTTL)
+	}
+	return &summary, nil
+}
+
+// SaveClicks writes a batch of clicks in one transaction. Marking the batch's events as processed
+// fails on events another worker recorded meanwhile, rolling the whole batch back.
+func (r *shortCodeRepository) SaveClicks(ctx context.Context, batch *model.ClickBatch) error {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.SaveClicks")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(batch.EventIDs) > 0 {
+			now := time.Now()
+			processed := make([]model.ProcessedClickEvent, len(batch.EventIDs))
+			for i, id := range batch.EventIDs {
+				processed[i] = model.ProcessedClickEvent{EventID: id, ProcessedAt: now}
+			}
+			if err := tx.CreateInBatches(processed, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(batch.Logs) > 0 {
+			if err := tx.CreateInBatches(batch.Logs, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		// Links are updated in ID order and before the access statistics, so concurrent batches take
+		// their row locks in the same order and cannot deadlock
+		for _, delta := range batch.Clicks {
+			updates := map[string]interface{}{
+				"click_count":     gorm.Expr("click_count + ?", delta.Clicks),
+				"bot_click_count": gorm.Expr("bot_click_count + ?", delta.BotClicks),
+				"fallback_count":  gorm.Expr("fallback_count + ?", delta.Fallbacks),
+			}
+			if !delta.LastAccessedAt.IsZero() {
+				updates["last_accessed_at"] = gorm.Expr("GREATEST(last_accessed_at, ?)", delta.LastAccessedAt) // GREATEST ignores NULL
+			}
+
+			err := tx.Model(&model.ShortCode{}).
+				Where("id = ?", delta.ShortCodeID).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Upserted in key order for the same reason
+		accesses := slices.Clone(batch.Accesses)
+		slices.SortFunc(accesses, compareAccessKeys)
+		for _, stats := range accesses {
+			if err := recordAccessStats(tx, stats); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ProcessedEventIDs reports which of the given durable click events were already recorded
+func (r *shortCodeRepository) ProcessedEventIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	processed := make(map[string]bool)
+	if len(ids) == 0 {
+		return processed, nil
+	}
+
+	var found []string
+	err := r.db.WithContext(ctx).
+		Model(&model.ProcessedClickEvent{}).
+		Where("event_id IN ?", ids).
+		Pluck("event_id", &found).Error
+	if err != nil {
 		return nil, err
 	}
-	metrics["active_codes"] = activeCodes
 
-	return metrics, nil
+	for _, id := range found {
+		processed[id] = true
+	}
+	return processed, nil
+}
+
+// PruneProcessedEvents forgets durable click events processed before the given time
+func (r *shortCodeRepository) PruneProcessedEvents(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("processed_at < ?", before).
+		Delete(&model.ProcessedClickEvent{})
+	return result.RowsAffected, result.Error
 }
 
-// RecordAccessStats records or updates access statistics for an hour bucket
+// RecordAccessStats records or updates access statistics for an hour bucket, adding stats.AccessCount accesses (at least one)
 func (r *shortCodeRepository) RecordAccessStats(ctx context.Context, stats *model.AccessStatistics) error {
-	// Try to find existing record for this shortcode, IP, and hour bucket
-	var existing model.AccessStatistics
-	err := r.db.WithContext(ctx).
-		Where("short_code_id = ? AND ip_address = ? AND hour_bucket = ?",
-			stats.ShortCodeID, stats.IPAddress, stats.HourBucket).
-		First(&existing).Error
+	return recordAccessStats(r.db.WithContext(ctx), stats)
+}
 
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Create new record
-			stats.AccessCount = 1
-			return r.db.WithContext(ctx).Create(stats).Error
-		}
-		return err
+// recordAccessStats upserts access statistics using the given connection or transaction. The row of a
+// shortcode, IP, hour bucket and bot flag is unique, concurrent writers add to it instead of inserting twice.
+func recordAccessStats(db *gorm.DB, stats *model.AccessStatistics) error {
+	stats.AccessCount = max(stats.AccessCount, 1)
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "short_code_id"}, {Name: "ip_address"}, {Name: "hour_bucket"}, {Name: "is_bot"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"access_count": gorm.Expr("access_statistics.access_count + EXCLUDED.access_count"),
+			"updated_at":   gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(stats).Error
+}
+
+// compareAccessKeys orders access statistics by their unique key
+func compareAccessKeys(a, b *model.AccessStatistics) int {
+	if a.ShortCodeID != b.ShortCodeID {
+		return cmp.Compare(a.ShortCodeID, b.ShortCodeID)
+	}
+	if a.IPAddress != b.IPAddress {
+		return strings.Compare(a.IPAddress, b.IPAddress)
+	}
+	if c := a.HourBucket.Compare(b.HourBucket); c != 0 {
+		return c
+	}
+	switch {
+	case a.IsBot == b.IsBot:
+		return 0
+	case a.IsBot:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// mergeDuplicateAccessStats adds up access statistics rows sharing the key columns into the oldest of them
+// and deletes the others
+func mergeDuplicateAccessStats(db *gorm.DB, keyColumns string) error {
+	var joins []string
+	for _, column := range strings.Split(keyColumns, ", ") {
+		joins = append(joins, fmt.Sprintf("a.%s = b.%s", column, column))
 	}
 
-	// Update existing record
-	return r.db.WithContext(ctx).
-		Model(&existing).
-		UpdateColumn("access_count", gorm.Expr("access_count + ?", 1)).
-		Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE access_statistics a SET access_count = d.total
+			FROM (SELECT MIN(id) AS id, SUM(access_count) AS total FROM access_statistics
+				WHERE ip_address IS NOT NULL
+				GROUP BY ` + keyColumns + ` HAVING COUNT(*) > 1) d
+			WHERE a.id = d.id`).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`DELETE FROM access_statistics a USING access_statistics b
+			WHERE ` + strings.Join(joins, " AND ") + ` AND a.id > b.id`).Error
+	})
 }
 
 // GetDetailedStats gets detailed statistics for a shortcode
-func (r *shortCodeRepository) GetDetailedStats(ctx context.Context, code string, hours int) (*model.DetailedStats, error) {
+func (r *shortCodeRepository) GetDetailedStats(ctx context.Context, code string, filter *model.StatsQuery) (*model.DetailedStats, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.GetDetailedStats")
+	defer span.End()
+
 	// Get basic shortcode info
 	var shortCode model.ShortCode
 	err := r.db.WithContext(ctx).
@@ -297,92 +829,116 @@ func (r *shortCodeRepository) GetDetailedStats(ctx context.Context, code string,
 		TotalClicks:    shortCode.ClickCount,
 		CreatedAt:      shortCode.CreatedAt,
 		LastAccessedAt: shortCode.LastAccessedAt,
+		RedirectType:   shortCode.RedirectType,
+		MaxClicks:      shortCode.MaxClicks,
+		FallbackCount:  shortCode.FallbackCount,
+		BotClicks:      shortCode.BotClickCount,
 	}
 
-	// Calculate time range
-	var startTime time.Time
-	if hours > 0 {
-		startTime = time.Now().Add(-time.Duration(hours) * time.Hour)
+	// Report how much traffic the class filter left out
+	if filter.ExcludeInternal {
+		internal := *filter
+		internal.ExcludeInternal = false
+		err := r.accessStats(ctx, shortCode.ID, &internal).
+			Select("COALESCE(SUM(access_count), 0)").
+			Where("ip_class IN ?", model.InternalIPClasses).
+			Scan(&stats.InternalClicks).Error
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Get unique IP count
 	var uniqueIPs int64
-	query := r.db.WithContext(ctx).
-		Model(&model.AccessStatistics{}).
-		Where("short_code_id = ?", shortCode.ID)
-
-	if hours > 0 {
-		query = query.Where("hour_bucket >= ?", startTime)
-	}
-
-	err = query.Distinct("ip_address").Count(&uniqueIPs).Error
+	err = r.accessStats(ctx, shortCode.ID, filter).Where("is_bot = ?", false).Distinct("ip_address").Count(&uniqueIPs).Error
 	if err != nil {
 		return nil, err
 	}
 	stats.UniqueIPs = uniqueIPs
 
-	// Get hourly statistics
-	var hourlyStats []model.HourlyStatItem
-	hourlyQuery := r.db.WithContext(ctx).
-		Model(&model.AccessStatistics{}).
-		Select("hour_bucket, SUM(access_count) as access_count, COUNT(DISTINCT ip_address) as unique_ips").
-		Where("short_code_id = ?", shortCode.ID)
+	// Get location statistics
+	var locationStats []model.LocationStatItem
+	err = r.accessStats(ctx, shortCode.ID, filter).
+		Select("country, region, city, SUM(access_count) as access_count").
+		Where("is_bot = ?", false).
+		Group("country, region, city").
+		Order("access_count DESC").
+		Limit(50).
+		Scan(&locationStats).Error
 
-	if hours > 0 {
-		hourlyQuery = hourlyQuery.Where("hour_bucket >= ?", startTime)
+	if err != nil {
+		return nil, err
 	}
+	stats.LocationStats = locationStats
 
-	err = hourlyQuery.
-		Group("hour_bucket").
-		Order("hour_bucket DESC").
-		Limit(100).
-		Scan(&hourlyStats).Error
+	// Get browser, operating system and device breakdowns
+	breakdowns := []struct {
+		column string
+		items  *[]model.BreakdownItem
+	}{
+		{"click_logs.browser", &stats.BrowserStats},
+		{"click_logs.os", &stats.OSStats},
+		{"click_logs.device", &stats.DeviceStats},
+	}
+	for _, breakdown := range breakdowns {
+		name := fmt.Sprintf("COALESCE(NULLIF(%s, ''), 'Unknown')", breakdown.column) // Clicks recorded before parsing
+		err = r.clickLogs(ctx, shortCode.ID, filter).
+			Select(name + " as name, COUNT(*) as access_count").
+			Group(name).
+			Order("access_count DESC").
+			Limit(20).
+			Scan(breakdown.items).Error
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	// Get referrer breakdowns of human clicks, bots rarely send a referrer and would inflate "direct"
+	referrerSource := "COALESCE(NULLIF(click_logs.referrer_source, ''), 'Unknown')" // Clicks recorded before normalization
+	referrerGroup := "COALESCE(NULLIF(click_logs.referrer_group, ''), 'Unknown')"
+	err = r.clickLogs(ctx, shortCode.ID, filter).
+		Select(referrerGroup + " as name, COUNT(*) as access_count").
+		Where("NOT click_logs.is_bot").
+		Group(referrerGroup).
+		Order("access_count DESC").
+		Scan(&stats.ReferrerGroups).Error
 	if err != nil {
 		return nil, err
 	}
-	stats.HourlyStats = hourlyStats
-
-	// Get location statistics
-	var locationStats []model.LocationStatItem
-	locationQuery := r.db.WithContext(ctx).
-		Model(&model.AccessStatistics{}).
-		Select("country, region, city, SUM(access_count) as access_count").
-		Where("short_code_id = ?", shortCode.ID)
 
-	if hours > 0 {
-		locationQuery = locationQuery.Where("hour_bucket >= ?", startTime)
+	err = r.clickLogs(ctx, shortCode.ID, filter).
+		Select(fmt.Sprintf(`%s as source, %s as "group", COUNT(*) as access_count`, referrerSource, referrerGroup)).
+		Where("NOT click_logs.is_bot").
+		Group(referrerSource + ", " + referrerGroup).
+		Order("access_count DESC").
+		Limit(20).
+		Scan(&stats.ReferrerStats).Error
+	if err != nil {
+		return nil, err
 	}
 
-	err = locationQuery.
-		Group("country, region, city").
+	err = r.clickLogs(ctx, shortCode.ID, filter).
+		Select("click_logs.utm_source as source, click_logs.utm_medium as medium, " +
+			"click_logs.utm_campaign as campaign, COUNT(*) as access_count").
+		Where("NOT click_logs.is_bot").
+		Where("(click_logs.utm_source <> '' OR click_logs.utm_medium <> '' OR click_logs.utm_campaign <> '')").
+		Group("click_logs.utm_source, click_logs.utm_medium, click_logs.utm_campaign").
 		Order("access_count DESC").
-		Limit(50).
-		Scan(&locationStats).Error
-
+		Limit(20).
+		Scan(&stats.CampaignStats).Error
 	if err != nil {
 		return nil, err
 	}
-	stats.LocationStats = locationStats
 
 	// Get recent accesses (from click logs)
 	var recentAccesses []model.RecentAccessItem
-	recentQuery := r.db.WithContext(ctx).
-		Table("click_logs").
-		Select("click_logs.ip_address, click_logs.user_agent, click_logs.created_at as access_time, "+
-			"COALESCE(access_statistics.country, '') as country, "+
-			"COALESCE(access_statistics.region, '') as region, "+
+	err = r.clickLogs(ctx, shortCode.ID, filter).
+		Select("click_logs.ip_address, click_logs.user_agent, click_logs.is_bot, click_logs.created_at as access_time, " +
+			"click_logs.referrer_source as referrer, " +
+			"click_logs.browser, click_logs.os, click_logs.device, " +
+			"COALESCE(access_statistics.country, '') as country, " +
+			"COALESCE(access_statistics.region, '') as region, " +
 			"COALESCE(access_statistics.city, '') as city").
-		Joins("LEFT JOIN access_statistics ON click_logs.ip_address = access_statistics.ip_address AND "+
-			"click_logs.short_code_id = access_statistics.short_code_id AND "+
-			"DATE_TRUNC('hour', click_logs.created_at) = access_statistics.hour_bucket").
-		Where("click_logs.short_code_id = ?", shortCode.ID)
-
-	if hours > 0 {
-		recentQuery = recentQuery.Where("click_logs.created_at >= ?", startTime)
-	}
-
-	err = recentQuery.
 		Order("click_logs.created_at DESC").
 		Limit(20).
 		Scan(&recentAccesses).Error
@@ -394,3 +950,155 @@ func (r *shortCodeRepository) GetDetailedStats(ctx context.Context, code string,
 
 	return stats, nil
 }
+
+// GetTimeSeries gets the clicks of a shortcode per time bucket, only buckets with clicks are returned.
+// Minute buckets and time zones with offsets that are not whole hours are counted from the click
+// logs, the others from the hourly access statistics.
+func (r *shortCodeRepository) GetTimeSeries(ctx context.Context, code string, filter *model.StatsQuery) ([]model.TimeSeriesItem, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.GetTimeSeries")
+	defer span.End()
+
+	var shortCode model.ShortCode
+	err := r.db.WithContext(ctx).
+		Select("id").
+		Where("code = ?", code).
+		First(&shortCode).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var items []model.TimeSeriesItem
+	if filter.Granularity == model.GranularityMinute || !wholeHourOffsets(filter) {
+		err = r.clickLogs(ctx, shortCode.ID, filter).
+			Select("date_trunc(?, click_logs.created_at, ?) as bucket, "+
+				"SUM(CASE WHEN click_logs.is_bot THEN 0 ELSE 1 END) as access_count, "+
+				"SUM(CASE WHEN click_logs.is_bot THEN 1 ELSE 0 END) as bot_count, "+
+				"COUNT(DISTINCT CASE WHEN click_logs.is_bot THEN NULL ELSE click_logs.ip_address END) as unique_ips",
+				filter.Granularity, filter.Location.String()).
+			Group("bucket").
+			Order("bucket").
+			Scan(&items).Error
+	} else {
+		err = r.accessStats(ctx, shortCode.ID, filter).
+			Select("date_trunc(?, hour_bucket, ?) as bucket, "+
+				"SUM(CASE WHEN is_bot THEN 0 ELSE access_count END) as access_count, "+
+				"SUM(CASE WHEN is_bot THEN access_count ELSE 0 END) as bot_count, "+
+				"COUNT(DISTINCT CASE WHEN is_bot THEN NULL ELSE ip_address END) as unique_ips",
+				filter.Granularity, filter.Location.String()).
+			Group("bucket").
+			Order("bucket").
+			Scan(&items).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ExportClickLogs reads the click logs of a shortcode in batches ordered by ID and hands each batch to fn.
+// Each batch is a separate keyset query, so no rows pile up in memory and no transaction stays open
+// while fn writes them out.
+func (r *shortCodeRepository) ExportClickLogs(ctx context.Context, code string, filter *model.StatsQuery, batchSize int, fn func([]model.ClickExportRow) error) error {
+	ctx, span := tracer.Start(ctx, "ShortCodeRepository.ExportClickLogs")
+	defer span.End()
+
+	var shortCode model.ShortCode
+	err := r.db.WithContext(ctx).
+		Select("id").
+		Where("code = ?", code).
+		First(&shortCode).Error
+	if err != nil {
+		return err
+	}
+
+	var lastID uint
+	for {
+		var rows []model.ClickExportRow
+		err := r.clickLogs(ctx, shortCode.ID, filter).
+			Select("click_logs.id, click_logs.created_at as time, click_logs.ip_address, "+
+				"COALESCE(access_statistics.ip_class, '') as ip_class, "+
+				"COALESCE(access_statistics.country, '') as country, "+
+				"COALESCE(access_statistics.region, '') as region, "+
+				"COALESCE(access_statistics.city, '') as city, "+
+				"click_logs.is_bot, click_logs.user_agent, click_logs.browser, click_logs.browser_version, "+
+				"click_logs.os, click_logs.os_version, click_logs.device, click_logs.referer, "+
+				"click_logs.referrer_source, click_logs.referrer_group, "+
+				"click_logs.utm_source, click_logs.utm_medium, click_logs.utm_campaign").
+			Where("click_logs.id > ?", lastID).
+			Order("click_logs.id").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if err := fn(rows); err != nil {
+			return err
+		}
+		if len(rows) < batchSize {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// wholeHourOffsets reports whether the time zone is a whole number of hours from UTC over the range,
+// so that hourly access statistics line up with its buckets
+func wholeHourOffsets(filter *model.StatsQuery) bool {
+	for _, t := range []time.Time{filter.From, filter.To} {
+		if t.IsZero() {
+			continue
+		}
+		if _, offset := t.In(filter.Location).Zone(); offset%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// accessStats scopes access statistics to the short link, time range and IP classes, bots included.
+// Access statistics are hourly, a range starting within an hour includes the whole hour.
+func (r *shortCodeRepository) accessStats(ctx context.Context, shortCodeID uint, filter *model.StatsQuery) *gorm.DB {
+	query := r.db.WithContext(ctx).
+		Model(&model.AccessStatistics{}).
+		Where("short_code_id = ?", shortCodeID)
+	if !filter.From.IsZero() {
+		query = query.Where("hour_bucket >= ?", filter.From.Truncate(time.Hour))
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("hour_bucket < ?", filter.To)
+	}
+	if filter.ExcludeInternal {
+		query = query.Where("ip_class NOT IN ?", model.InternalIPClasses)
+	}
+	return query
+}
+
+// clickLogs scopes click logs joined with their access statistics to the same filters
+func (r *shortCodeRepository) clickLogs(ctx context.Context, shortCodeID uint, filter *model.StatsQuery) *gorm.DB {
+	query := r.db.WithContext(ctx).
+		Table("click_logs").
+		Joins("LEFT JOIN access_statistics ON click_logs.ip_address = access_statistics.ip_address AND "+
+			"click_logs.short_code_id = access_statistics.short_code_id AND "+
+			"click_logs.is_bot = access_statistics.is_bot AND "+
+			"DATE_TRUNC('hour', click_logs.created_at) = access_statistics.hour_bucket").
+		Where("click_logs.short_code_id = ?", shortCodeID)
+	if !filter.From.IsZero() {
+		query = query.Where("click_logs.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("click_logs.created_at < ?", filter.To)
+	}
+	if filter.ExcludeInternal {
+		query = query.Where("COALESCE(access_statistics.ip_class, '') NOT IN ?", model.InternalIPClasses)
+	}
+	return query
+}
+
+// escapeLike escapes LIKE wildcards so the pattern matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
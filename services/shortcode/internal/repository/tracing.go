@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Statement instance keys of the query span and the context it replaced
+const (
+	querySpanKey   = "tracing:span"
+	queryParentKey = "tracing:parent"
+)
+
+// queryTracer GORM plugin creating a client span per SQL statement. The statement text is recorded
+// with placeholders only, bound values such as IPs and password hashes stay out of the traces.
+type queryTracer struct{}
+
+// Name implements gorm.Plugin
+func (queryTracer) Name() string {
+	return "tracing"
+}
+
+// Initialize registers the span callbacks around every kind of statement
+func (t queryTracer) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", t.start("create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", t.end),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", t.start("query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", t.end),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", t.start("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", t.end),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", t.start("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", t.end),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", t.start("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", t.end),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", t.start("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", t.end),
+	)
+}
+
+// start opens the span of a statement in its context
+func (queryTracer) start(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+
+		ctx, span := tracer.Start(parent, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)))
+		tx.InstanceSet(querySpanKey, span)
+		tx.InstanceSet(queryParentKey, parent)
+		tx.Statement.Context = ctx
+	}
+}
+
+// end closes the span of a statement and restores the context, statements built once and run
+// repeatedly would otherwise nest under ended spans
+func (queryTracer) end(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(querySpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	if parent, ok := tx.InstanceGet(queryParentKey); ok {
+		tx.Statement.Context = parent.(context.Context)
+	}
+
+	span.SetAttributes(semconv.DBQueryText(tx.Statement.SQL.String()), semconv.DBCollectionName(tx.Statement.Table))
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, "query failed")
+	}
+	span.End()
+}
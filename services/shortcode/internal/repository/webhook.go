@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// ErrDeadLetterNotFound the dead letter does not exist or was already replayed
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// WebhookRepository webhook, delivery and dead letter repository interface
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *model.Webhook) error
+	GetByID(ctx context.Context, id uint) (*model.Webhook, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]model.Webhook, error)
+	List(ctx context.Context, ownerID *uint) ([]model.Webhook, error)
+	CountByOwner(ctx context.Context, ownerID uint) (int64, error)
+	ListActive(ctx context.Context) ([]model.Webhook, error)
+	Update(ctx context.Context, id uint, updates map[string]interface{}) (*model.Webhook, error)
+	Delete(ctx context.Context, id uint) error
+
+	CreateDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	SaveDeliveryResult(ctx context.Context, delivery *model.WebhookDelivery) error
+	MoveToDeadLetters(ctx context.Context, delivery *model.WebhookDelivery, now time.Time) error
+	ListDeliveries(ctx context.Context, filter *model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error)
+	ListDeadLetters(ctx context.Context, webhookID, beforeID uint, limit int) ([]model.WebhookDeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, webhookID, id uint, now time.Time) (*model.WebhookDelivery, error)
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository create webhook repository instance
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+// Create create webhook
+func (r *webhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+// GetByID get webhook by ID
+func (r *webhookRepository) GetByID(ctx context.Context, id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&webhook).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// GetByIDs get webhooks by ID, deleted webhooks are left out
+func (r *webhookRepository) GetByIDs(ctx context.Context, ids []uint) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	if len(ids) == 0 {
+		return webhooks, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// List list the webhooks of an API key, all webhooks for nil
+func (r *webhookRepository) List(ctx context.Context, ownerID *uint) ([]model.Webhook, error) {
+	query := r.db.WithContext(ctx)
+	if ownerID != nil {
+		query = query.Where("owner_id = ?", *ownerID)
+	}
+
+	var webhooks []model.Webhook
+	err := query.Order("id ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// CountByOwner count the webhooks of an API key
+func (r *webhookRepository) CountByOwner(ctx context.Context, ownerID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Webhook{}).
+		Where("owner_id = ?", ownerID).
+		Count(&count).Error
+	return count, err
+}
+
+// ListActive list all enabled webhooks
+func (r *webhookRepository) ListActive(ctx context.Context) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := r.db.WithContext(ctx).
+		Where("disabled = ?", false).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// Update update webhook fields and return the updated webhook
+func (r *webhookRepository) Update(ctx context.Context, id uint, updates map[string]interface{}) (*model.Webhook, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Webhook{}).
+		Where("id = ?", id).
+		Updates(updates)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("webhook not found")
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// Delete delete webhook, its pending deliveries are no longer sent
+func (r *webhookRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.Webhook{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
+
+// CreateDeliveries queue deliveries in one insert
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(deliveries, 500).Error
+}
+
+// ClaimDueDeliveries takes pending deliveries of enabled webhooks that are due and counts their next
+// attempt. They are leased: if the attempt is not finished within the lease, e.g. because the server
+// crashed, they become due again. Locked rows are skipped, so several servers can send concurrently.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.WithContext(ctx).Raw(`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id AND w.deleted_at IS NULL AND NOT w.disabled
+			WHERE d.status = ? AND d.next_attempt_at <= ?
+			ORDER BY d.next_attempt_at
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), model.DeliveryPending, now, limit).
+		Scan(&deliveries).Error
+	return deliveries, err
+}
+
+// SaveDeliveryResult write the outcome of an attempt: the status, the next attempt and the response
+func (r *webhookRepository) SaveDeliveryResult(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).
+		Model(&model.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"response_status": delivery.ResponseStatus,
+			"last_error":      delivery.LastError,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+}
+
+// MoveToDeadLetters mark a delivery as dead and copy it to the dead letters, in a single transaction
+func (r *webhookRepository) MoveToDeadLetters(ctx context.Context, delivery *model.WebhookDelivery, now time.Time) error {
+	delivery.Status = model.DeliveryDead
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := (&webhookRepository{db: tx}).SaveDeliveryResult(ctx, delivery); err != nil {
+			return err
+		}
+		return tx.Create(&model.WebhookDeadLetter{
+			DeliveryID:     delivery.ID,
+			WebhookID:      delivery.WebhookID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+			Attempts:       delivery.Attempts,
+			ResponseStatus: delivery.ResponseStatus,
+			LastError:      delivery.LastError,
+			FailedAt:       now,
+		}).Error
+	})
+}
+
+// ListDeliveries list the delivery log of a webhook, newest first
+func (r *webhookRepository) ListDeliveries(ctx context.Context, filter *model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).
+		Where("webhook_id = ?", filter.WebhookID)
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var deliveries []model.WebhookDelivery
+	err := query.
+		Order("id DESC").
+		Limit(filter.Limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListDeadLetters list the dead letters of a webhook, newest first
+func (r *webhookRepository) ListDeadLetters(ctx context.Context, webhookID, beforeID uint, limit int) ([]model.WebhookDeadLetter, error) {
+	query := r.db.WithContext(ctx).
+		Where("webhook_id = ?", webhookID)
+
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var letters []model.WebhookDeadLetter
+	err := query.
+		Order("id DESC").
+		Limit(limit).
+		Find(&letters).Error
+	return letters, err
+}
+
+// ReplayDeadLetter queue a new delivery of a dead letter and remove the dead letter, in a single transaction
+func (r *webhookRepository) ReplayDeadLetter(ctx context.Context, webhookID, id uint, now time.Time) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var letter model.WebhookDeadLetter
+		err := tx.Where("id = ? AND webhook_id = ?", id, webhookID).First(&letter).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeadLetterNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// The delete decides between concurrent replays of the same letter
+		result := tx.Delete(&letter)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeadLetterNotFound
+		}
+
+		delivery = &model.WebhookDelivery{
+			WebhookID:     letter.WebhookID,
+			EventID:       letter.EventID,
+			EventType:     letter.EventType,
+			Payload:       letter.Payload,
+			Status:        model.DeliveryPending,
+			NextAttemptAt: now,
+		}
+		return tx.Create(delivery).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// PruneDeliveries remove deliveries and dead letters created before the given time
+func (r *webhookRepository) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("created_at < ?", before).Delete(&model.WebhookDelivery{})
+		if result.Error != nil {
+			return result.Error
+		}
+		pruned = result.RowsAffected
+
+		result = tx.Where("failed_at < ?", before).Delete(&model.WebhookDeadLetter{})
+		pruned += result.RowsAffected
+		return result.Error
+	})
+	return pruned, err
+}
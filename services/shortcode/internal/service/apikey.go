@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+)
+
+var (
+	// ErrInvalidAPIKey API key is unknown or revoked
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrUnauthorized request is not authenticated
+	ErrUnauthorized = errors.New("authentication required")
+	// ErrForbidden caller is not allowed to access the resource
+	ErrForbidden = errors.New("permission denied")
+	// ErrAPIKeyNotFound API key not found
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAdminKeyRevoked the configured bootstrap admin key was revoked and is not registered again
+	ErrAdminKeyRevoked = errors.New("bootstrap admin API key was revoked")
+)
+
+const (
+	apiKeyPrefix       = "sk_"
+	apiKeyLength       = 32
+	apiKeyDisplayChars = 8
+	// lastUsedResolution avoids writing the last used time on every request
+	lastUsedResolution = time.Minute
+)
+
+// APIKeyService API key service interface
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, req *model.CreateAPIKeyRequest, caller *model.APIKey) (*model.CreateAPIKeyResponse, error)
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uint, caller *model.APIKey) error
+	EnsureAdminKey(ctx context.Context, rawKey string) error
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+// NewAPIKeyService creates API key service instance
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		repo: repo,
+	}
+}
+
+// CreateAPIKey creates a new API key, only admin keys can create keys
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *model.CreateAPIKeyRequest, caller *model.APIKey) (*model.CreateAPIKeyResponse, error) {
+	if err := requireAdmin(caller); err != nil {
+		return nil, err
+	}
+
+	rawKey := apiKeyPrefix + generateRandomCode(apiKeyLength)
+	key := &model.APIKey{
+		Name:      req.Name,
+		KeyPrefix: rawKey[:len(apiKeyPrefix)+apiKeyDisplayChars],
+		KeyHash:   hashAPIKey(rawKey),
+		IsAdmin:   req.IsAdmin,
+	}
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &model.CreateAPIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Key:       rawKey,
+		KeyPrefix: key.KeyPrefix,
+		IsAdmin:   key.IsAdmin,
+		CreatedAt: key.CreatedAt,
+	}, nil
+}
+
+// Authenticate resolves the API key matching the raw key
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error) {
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > lastUsedResolution {
+		// Usage tracking is best effort and must not fail the request
+		_ = s.repo.TouchLastUsed(ctx, key.ID)
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey revokes an API key, only admin keys can revoke keys
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id uint, caller *model.APIKey) error {
+	if err := requireAdmin(caller); err != nil {
+		return err
+	}
+
+	if err := s.repo.Revoke(ctx, id); err != nil {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// EnsureAdminKey registers the configured bootstrap admin key if it does not exist yet.
+// A revoked bootstrap key stays revoked, ErrAdminKeyRevoked is returned instead.
+func (s *apiKeyService) EnsureAdminKey(ctx context.Context, rawKey string) error {
+	keyHash := hashAPIKey(rawKey)
+	existing, err := s.repo.FindByHashUnscoped(ctx, keyHash)
+	if err != nil {
+		return fmt.Errorf("failed to look up admin api key: %w", err)
+	}
+	if existing != nil {
+		if existing.DeletedAt.Valid {
+			return ErrAdminKeyRevoked
+		}
+		return nil
+	}
+
+	prefixLen := min(len(rawKey), len(apiKeyPrefix)+apiKeyDisplayChars)
+	key := &model.APIKey{
+		Name:      "bootstrap admin",
+		KeyPrefix: rawKey[:prefixLen],
+		KeyHash:   keyHash,
+		IsAdmin:   true,
+	}
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return fmt.Errorf("failed to create admin api key: %w", err)
+	}
+	return nil
+}
+
+// hashAPIKey hashes the raw key, keys are random so a fast hash is sufficient
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireAdmin checks that the caller is an admin key
+func requireAdmin(caller *model.APIKey) error {
+	if caller == nil {
+		return ErrUnauthorized
+	}
+	if !caller.IsAdmin {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// canManage checks if the caller owns the short link or is an admin
+func canManage(caller *model.APIKey, shortCode *model.ShortCode) error {
+	if caller == nil {
+		return ErrUnauthorized
+	}
+	if caller.IsAdmin {
+		return nil
+	}
+	if shortCode.OwnerID == nil || *shortCode.OwnerID != caller.ID {
+		return ErrForbidden
+	}
+	return nil
+}
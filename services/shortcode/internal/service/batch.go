@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// ErrDuplicateInBatch custom code used by several items of the same batch
+var ErrDuplicateInBatch = errors.New("duplicate code in batch")
+
+// CreateShortCodesBatch creates many short links in one call.
+// By default the batch is atomic: either every item is created in a single transaction or none is.
+// In partial mode the valid items are created and failures are reported per item.
+func (s *shortCodeService) CreateShortCodesBatch(ctx context.Context, req *model.BatchCreateShortCodeRequest, caller *model.APIKey) (*model.BatchCreateShortCodeResponse, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.CreateShortCodesBatch")
+	defer span.End()
+
+	results := make([]model.BatchCreateResult, len(req.Items))
+	shortCodes := make([]*model.ShortCode, len(req.Items)) // nil for failed items
+	taken := make(map[string]bool)
+
+	var customCodes []string
+	customIndex := make(map[string]int)
+
+	// Validate every item before touching the database
+	for i := range req.Items {
+		results[i].Index = i
+
+		shortCode, err := s.newShortCode(&req.Items[i], caller)
+		if err != nil {
+			setBatchError(&results[i], err)
+			continue
+		}
+
+		if shortCode.Code != "" {
+			if taken[shortCode.Code] {
+				setBatchError(&results[i], ErrDuplicateInBatch)
+				continue
+			}
+			taken[shortCode.Code] = true
+			customCodes = append(customCodes, shortCode.Code)
+			customIndex[shortCode.Code] = i
+		}
+
+		shortCodes[i] = shortCode
+	}
+
+	// Reject custom codes that are already taken
+	existing, err := s.repo.ExistingCodes(ctx, customCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check code existence: %w", err)
+	}
+	for _, code := range existing {
+		i := customIndex[code]
+		setBatchError(&results[i], ErrCodeExists)
+		shortCodes[i] = nil
+	}
+
+	if err := s.assignGeneratedCodes(ctx, shortCodes, taken); err != nil {
+		return nil, fmt.Errorf("failed to generate unique codes: %w", err)
+	}
+
+	valid := make([]*model.ShortCode, 0, len(shortCodes))
+	for _, shortCode := range shortCodes {
+		if shortCode != nil {
+			valid = append(valid, shortCode)
+		}
+	}
+
+	resp := &model.BatchCreateShortCodeResponse{
+		Results: results,
+	}
+
+	// Atomic mode: one invalid item rejects the whole batch
+	if len(valid) < len(req.Items) && !req.Partial {
+		for i := range results {
+			if shortCodes[i] != nil {
+				results[i].Error = "batch_aborted"
+				results[i].Message = "Not created because other items of the batch failed"
+			}
+		}
+		resp.Failed = len(req.Items)
+		return resp, nil
+	}
+
+	if err := s.repo.CreateBatch(ctx, valid); err != nil {
+		if !req.Partial {
+			return nil, fmt.Errorf("failed to create short codes: %w", err)
+		}
+
+		// Fall back to one insert per item so a single conflict does not fail the others
+		for i, shortCode := range shortCodes {
+			if shortCode == nil {
+				continue
+			}
+			shortCode.ID = 0
+			if err := s.repo.Create(ctx, shortCode); err != nil {
+				setBatchError(&results[i], err)
+				shortCodes[i] = nil
+			}
+		}
+	}
+
+	for i, shortCode := range shortCodes {
+		if shortCode == nil {
+			resp.Failed++
+			continue
+		}
+		results[i].Success = true
+		results[i].Result = s.toCreateResponse(shortCode)
+		resp.Succeeded++
+		s.publish(ctx, model.EventLinkCreated, shortCode)
+	}
+
+	return resp, nil
+}
+
+// assignGeneratedCodes generates random codes for items without a custom code, checking all candidates in one query per round
+func (s *shortCodeService) assignGeneratedCodes(ctx context.Context, shortCodes []*model.ShortCode, taken map[string]bool) error {
+	for i := 0; i < maxRetries; i++ {
+		candidates := make(map[string]*model.ShortCode)
+		for _, shortCode := range shortCodes {
+			if shortCode == nil || shortCode.Code != "" {
+				continue
+			}
+			code := generateRandomCode(defaultCodeLength)
+			if taken[code] {
+				continue // Collision within the batch, retry in the next round
+			}
+			taken[code] = true
+			shortCode.Code = code
+			candidates[code] = shortCode
+		}
+
+		if len(candidates) == 0 {
+			if !hasMissingCode(shortCodes) {
+				return nil
+			}
+			continue
+		}
+
+		codes := make([]string, 0, len(candidates))
+		for code := range candidates {
+			codes = append(codes, code)
+		}
+
+		existing, err := s.repo.ExistingCodes(ctx, codes)
+		if err != nil {
+			return err
+		}
+		for _, code := range existing {
+			candidates[code].Code = ""
+		}
+
+		if !hasMissingCode(shortCodes) {
+			return nil
+		}
+	}
+
+	return errors.New("failed to generate unique codes after max retries")
+}
+
+// hasMissingCode reports whether a valid item still has no code
+func hasMissingCode(shortCodes []*model.ShortCode) bool {
+	for _, shortCode := range shortCodes {
+		if shortCode != nil && shortCode.Code == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setBatchError records the error of a batch item
+func setBatchError(result *model.BatchCreateResult, err error) {
+	result.Success = false
+	switch {
+	case errors.Is(err, ErrInvalidURL):
+		result.Error = "invalid_url"
+		result.Message = "The provided URL is not valid"
+	case errors.Is(err, ErrInvalidCode):
+		result.Error = "invalid_code"
+		result.Message = "The code format is invalid (4-50 alphanumeric characters)"
+	case errors.Is(err, ErrCodeExists):
+		result.Error = "code_exists"
+		result.Message = "The custom code already exists"
+	case errors.Is(err, ErrInvalidRedirectType):
+		result.Error = "invalid_redirect_type"
+		result.Message = "The redirect type must be 301, 302, 307 or 308"
+	case errors.Is(err, ErrInvalidFallbackURL):
+		result.Error = "invalid_fallback_url"
+		result.Message = "The fallback URL is not valid"
+	case errors.Is(err, ErrInvalidSchedule):
+		result.Error = "invalid_schedule"
+		result.Message = "expires_at must be in the future and after activates_at, and cannot be combined with expires_in"
+	case errors.Is(err, ErrInvalidMaxClicks):
+		result.Error = "invalid_max_clicks"
+		result.Message = "The click limit must be a positive number"
+	case errors.Is(err, ErrInvalidPassword):
+		result.Error = "invalid_password"
+		result.Message = "The password must be 4-72 characters"
+	case errors.Is(err, ErrDuplicateInBatch):
+		result.Error = "duplicate_code"
+		result.Message = "The custom code is used by another item of the batch"
+	default:
+		result.Error = "internal_error"
+		result.Message = "Failed to create short code"
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/referrer"
+	"github.com/lincyaw/tools/services/shortcode/internal/telemetry"
+	"github.com/lincyaw/tools/services/shortcode/internal/useragent"
+)
+
+// ClickSink receives click events from the redirect path, Enqueue must not block the request for long.
+// The context carries the request span, the event must outlive its cancellation.
+type ClickSink interface {
+	Enqueue(ctx context.Context, event *model.ClickEvent) bool
+	Stats() ClickSinkStats
+}
+
+// ClickSinkStats counters of a click sink, exposed on the metrics endpoint
+type ClickSinkStats struct {
+	Enqueued  uint64 `json:"enqueued"`
+	Dropped   uint64 `json:"dropped"`
+	Processed uint64 `json:"processed"`
+	Failed    uint64 `json:"failed"`
+	Pending   int    `json:"pending"`
+}
+
+// accessKey groups clicks of one short link by IP and hour for access statistics
+type accessKey struct {
+	shortCodeID uint
+	ipAddress   string
+	hourBucket  time.Time
+	bot         bool
+}
+
+// RecordClicks records a batch of click events in one transaction. Durable events that were
+// already recorded are skipped, so redelivered events are not counted twice.
+func (s *shortCodeService) RecordClicks(ctx context.Context, events []*model.ClickEvent) error {
+	// A batch serves many requests, so it is linked to their spans instead of having a parent among them
+	var eventIDs []string
+	var links []trace.Link
+	for _, event := range events {
+		if event.ID != "" {
+			eventIDs = append(eventIDs, event.ID)
+		}
+		if spanContext := telemetry.ExtractSpanContext(event.TraceContext); spanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: spanContext})
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "ShortCodeService.RecordClicks",
+		trace.WithLinks(links...), trace.WithAttributes(attribute.Int("shortcode.clicks", len(events))))
+	defer span.End()
+
+	processed, err := s.repo.ProcessedEventIDs(ctx, eventIDs)
+	if err != nil {
+		return fmt.Errorf("failed to check processed clicks: %w", err)
+	}
+
+	batch := &model.ClickBatch{}
+	codes := make([]string, 0, len(events))
+	seen := make(map[string]bool)
+	pending := make([]*model.ClickEvent, 0, len(events))
+	for _, event := range events {
+		if event.ID != "" {
+			if processed[event.ID] {
+				continue
+			}
+			batch.EventIDs = append(batch.EventIDs, event.ID)
+		}
+		pending = append(pending, event)
+
+		if !seen[event.Code] {
+			seen[event.Code] = true
+			codes = append(codes, event.Code)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	refs, err := s.repo.FindRefsByCodes(ctx, codes)
+	if err != nil {
+		return fmt.Errorf("failed to get short codes: %w", err)
+	}
+
+	deltas := make(map[uint]*model.ClickCountDelta)
+	accesses := make(map[accessKey]int64)
+	agents := make(map[string]useragent.Info) // Parse each user agent once per batch
+	var clicks []model.RecordedClick          // Published once saved, geo data is added when locations are resolved
+	var clickIPs []string
+	for _, event := range pending {
+		link, ok := refs[event.Code]
+		if !ok {
+			continue // Deleted since the redirect
+		}
+		id := link.ID
+		ipAddress := normalizeIP(event.IPAddress)
+
+		delta, ok := deltas[id]
+		if !ok {
+			delta = &model.ClickCountDelta{ShortCodeID: id}
+			deltas[id] = delta
+		}
+
+		// Fallback hits are counted apart from the clicks of the link
+		if event.Fallback {
+			delta.Fallbacks++
+			continue
+		}
+
+		// Bot clicks are counted apart, clicks on click-limited links were already counted by the redirect
+		if event.Bot || !event.Counted {
+			if event.Bot {
+				delta.BotClicks++
+			} else {
+				delta.Clicks++
+				if event.OccurredAt.After(delta.LastAccessedAt) {
+					delta.LastAccessedAt = event.OccurredAt
+				}
+			}
+		}
+
+		agent, ok := agents[event.UserAgent]
+		if !ok {
+			agent = useragent.Parse(event.UserAgent)
+			agents[event.UserAgent] = agent
+		}
+		if event.Bot {
+			agent.Device = model.DeviceBot // Also covers unfurlers detected by request headers only
+		}
+		ref := referrer.Parse(event.Referer)
+
+		batch.Logs = append(batch.Logs, &model.ClickLog{
+			ShortCodeID:    id,
+			IPAddress:      ipAddress,
+			UserAgent:      event.UserAgent,
+			Referer:        event.Referer,
+			Browser:        agent.Browser,
+			BrowserVersion: agent.BrowserVersion,
+			OS:             agent.OS,
+			OSVersion:      agent.OSVersion,
+			Device:         agent.Device,
+			ReferrerSource: ref.Source,
+			ReferrerGroup:  ref.Group,
+			UTMSource:      ref.UTMSource,
+			UTMMedium:      ref.UTMMedium,
+			UTMCampaign:    ref.UTMCampaign,
+			IsBot:          event.Bot,
+			CreatedAt:      event.OccurredAt,
+		})
+
+		clicks = append(clicks, model.RecordedClick{
+			Code:           event.Code,
+			OccurredAt:     event.OccurredAt,
+			Bot:            event.Bot,
+			Browser:        agent.Browser,
+			OS:             agent.OS,
+			Device:         agent.Device,
+			ReferrerSource: ref.Source,
+			ReferrerGroup:  ref.Group,
+			UTMSource:      ref.UTMSource,
+			UTMMedium:      ref.UTMMedium,
+			UTMCampaign:    ref.UTMCampaign,
+			OwnerID:        link.OwnerID,
+		})
+		clickIPs = append(clickIPs, ipAddress)
+
+		accesses[accessKey{
+			shortCodeID: id,
+			ipAddress:   ipAddress,
+			hourBucket:  event.OccurredAt.Truncate(time.Hour),
+			bot:         event.Bot,
+		}]++
+	}
+
+	// Ordered by ID, so concurrent batches lock the rows of their links in the same order and cannot deadlock
+	for _, delta := range deltas {
+		batch.Clicks = append(batch.Clicks, *delta)
+	}
+	slices.SortFunc(batch.Clicks, func(a, b model.ClickCountDelta) int {
+		return cmp.Compare(a.ShortCodeID, b.ShortCodeID)
+	})
+
+	// Resolve each IP once per batch, before the transaction is opened
+	locations := make(map[string]model.IPLocation)
+	for key, count := range accesses {
+		ipClass := classifyIP(key.ipAddress)
+		location, ok := locations[key.ipAddress]
+		if !ok && !key.bot { // Locations are reported for humans only, bots do not use up lookups
+			location = s.getIPLocation(ctx, key.ipAddress, ipClass)
+			locations[key.ipAddress] = location
+		}
+
+		batch.Accesses = append(batch.Accesses, &model.AccessStatistics{
+			ShortCodeID: key.shortCodeID,
+			IPAddress:   key.ipAddress,
+			IPClass:     ipClass,
+			Country:     location.Country,
+			Region:      location.Region,
+			City:        location.City,
+			HourBucket:  key.hourBucket,
+			AccessCount: count,
+			IsBot:       key.bot,
+		})
+	}
+
+	if err := s.repo.SaveClicks(ctx, batch); err != nil {
+		return fmt.Errorf("failed to save clicks: %w", err)
+	}
+
+	if len(clicks) > 0 {
+		for i := range clicks {
+			location := locations[clickIPs[i]]
+			clicks[i].IPClass = classifyIP(clickIPs[i])
+			clicks[i].Country = location.Country
+			clicks[i].Region = location.Region
+			clicks[i].City = location.City
+		}
+
+		if err := s.live.Publish(ctx, clicks); err != nil {
+			slog.WarnContext(ctx, "Failed to publish live clicks", "count", len(clicks), "error", err)
+		}
+		if s.events != nil {
+			s.events.PublishClicks(ctx, clicks)
+		}
+	}
+	return nil
+}
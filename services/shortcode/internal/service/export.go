@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// exportBatchSize click logs read from the database per batch of an export
+const exportBatchSize = 1000
+
+// ExportClicks streams the click logs of a link in the range to fn, batch by batch.
+// Only the owner or an admin can export them.
+func (s *shortCodeService) ExportClicks(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey, fn func([]model.ClickExportRow) error) error {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.ExportClicks", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
+	if _, err := s.authorize(ctx, code, caller); err != nil {
+		return err
+	}
+
+	filter, err := normalizeStatsQuery(query)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ExportClickLogs(ctx, code, filter, exportBatchSize, fn); err != nil {
+		return fmt.Errorf("failed to export clicks: %w", err)
+	}
+	return nil
+}
+
+// ExportTimeSeries gets the gap-filled time series of a link for export, it may span more buckets
+// than the series of the detailed statistics. Only the owner or an admin can export it.
+func (s *shortCodeService) ExportTimeSeries(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey) ([]model.TimeSeriesItem, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.ExportTimeSeries", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
+	shortCode, err := s.authorize(ctx, code, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := normalizeStatsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := timeSeriesBuckets(filter, shortCode.CreatedAt, maxExportBuckets)
+	if err != nil {
+		return nil, err
+	}
+	if len(buckets) > 0 {
+		filter.From = buckets[0]
+	}
+
+	items, err := s.repo.GetTimeSeries(ctx, code, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time series: %w", err)
+	}
+	return fillTimeSeries(buckets, items), nil
+}
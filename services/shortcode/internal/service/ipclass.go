@@ -0,0 +1,74 @@
+package service
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+var (
+	// sharedAddressSpace carrier-grade NAT range (RFC 6598), not routable on the internet
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+	// reservedPrefixes special-purpose ranges (RFC 6890) that never carry public client traffic
+	reservedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+		netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+		netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+		netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+		netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+		netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+		netip.MustParsePrefix("240.0.0.0/4"),     // Future use, includes broadcast
+		netip.MustParsePrefix("100::/64"),        // Discard only
+		netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	}
+)
+
+// parseIP parses an IPv4 or IPv6 address, IPv4-mapped IPv6 addresses are unmapped and zones dropped
+func parseIP(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// classifyIP returns the IP class of an address, anything that does not parse as an IP is reserved
+func classifyIP(s string) string {
+	addr, ok := parseIP(s)
+	if !ok {
+		return model.IPClassReserved
+	}
+
+	switch {
+	case addr.IsLoopback():
+		return model.IPClassLoopback
+	case addr.IsPrivate(), addr.IsLinkLocalUnicast(), sharedAddressSpace.Contains(addr):
+		return model.IPClassPrivate
+	case addr.IsUnspecified(), addr.IsMulticast():
+		return model.IPClassReserved
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return model.IPClassReserved
+		}
+	}
+	return model.IPClassPublic
+}
+
+// normalizeIP returns the canonical form of an IP address, so that e.g. IPv4-mapped
+// and plain IPv4 clicks are grouped together. Invalid input is returned unchanged.
+func normalizeIP(s string) string {
+	addr, ok := parseIP(s)
+	if !ok {
+		return s
+	}
+	return addr.String()
+}
+
+// IsPublicIP reports whether an address is routable on the internet, e.g. to keep webhooks off internal hosts
+func IsPublicIP(s string) bool {
+	return classifyIP(s) == model.IPClassPublic
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// GeoLocator resolves the location of an IP address, empty fields mean the location is unknown
+type GeoLocator interface {
+	Locate(ctx context.Context, ipAddress string) (model.IPLocation, error)
+}
+
+// getIPLocation gets IP location information, only public addresses are looked up
+func (s *shortCodeService) getIPLocation(ctx context.Context, ipAddress, ipClass string) model.IPLocation {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.getIPLocation")
+	defer span.End()
+
+	// Default location
+	location := model.IPLocation{
+		Country: "Unknown",
+		Region:  "Unknown",
+		City:    "Unknown",
+	}
+
+	switch ipClass {
+	case model.IPClassPrivate, model.IPClassLoopback:
+		location.Country = "Private"
+		location.Region = "Local"
+		location.City = "Local"
+		return location
+	case model.IPClassReserved:
+		location.Country = "Reserved"
+		location.Region = "Reserved"
+		location.City = "Reserved"
+		return location
+	}
+
+	found, err := s.geo.Locate(ctx, ipAddress)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to locate IP", "ip", ipAddress, "error", err)
+		return location
+	}
+
+	if found.Country != "" {
+		location.Country = found.Country
+	}
+	if found.Region != "" {
+		location.Region = found.Region
+	}
+	if found.City != "" {
+		location.City = found.City
+	}
+	return location
+}
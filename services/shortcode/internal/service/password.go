@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+var (
+	// ErrPasswordRequired the short link is protected and no password was given
+	ErrPasswordRequired = errors.New("password required")
+	// ErrWrongPassword the given password does not match
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrInvalidPassword password does not meet the length requirements
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+const (
+	minPasswordLength = 4
+	maxPasswordLength = 72 // bcrypt ignores everything after 72 bytes
+)
+
+// hashPassword validates and hashes a short link password
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword verifies the password of a protected short link, links without password always pass
+func checkPassword(shortCode *model.ShortCode, password string) error {
+	if shortCode.PasswordHash == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(shortCode.PasswordHash), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
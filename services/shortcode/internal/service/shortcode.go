@@ -3,16 +3,22 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"net/url"
 	"regexp"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
 	"github.com/lincyaw/tools/services/shortcode/internal/model"
 	"github.com/lincyaw/tools/services/shortcode/internal/repository"
 )
@@ -26,171 +32,655 @@ var (
 	ErrCodeNotFound = errors.New("code not found")
 	// ErrInvalidCode invalid code format
 	ErrInvalidCode = errors.New("invalid code format")
+	// ErrEmptyUpdate update request without any field
+	ErrEmptyUpdate = errors.New("no fields to update")
+	// ErrInvalidCursor malformed or mismatched pagination cursor
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidRedirectType unsupported redirect status code
+	ErrInvalidRedirectType = errors.New("invalid redirect type")
+	// ErrInvalidMaxClicks negative click limit
+	ErrInvalidMaxClicks = errors.New("invalid max clicks")
+	// ErrLinkExhausted the click limit of the link is reached
+	ErrLinkExhausted = errors.New("link exhausted")
+	// ErrInvalidSchedule conflicting or past activation and expiration times
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	// ErrNotYetActive the link activation time has not come yet
+	ErrNotYetActive = errors.New("link not yet active")
+	// ErrLinkExpired the link has expired
+	ErrLinkExpired = errors.New("link expired")
+	// ErrLinkDisabled the link was disabled by its owner
+	ErrLinkDisabled = errors.New("link disabled")
+	// ErrInvalidFallbackURL invalid fallback URL
+	ErrInvalidFallbackURL = errors.New("invalid fallback URL")
+	// ErrInvalidGranularity unsupported time series granularity
+	ErrInvalidGranularity = errors.New("invalid granularity")
+	// ErrInvalidTimeRange statistics range that ends before it starts
+	ErrInvalidTimeRange = errors.New("invalid time range")
+	// ErrTooManyBuckets statistics range with more time series buckets than allowed
+	ErrTooManyBuckets = errors.New("too many time series buckets")
 )
 
+// tracer creates the spans of the service layer
+var tracer = otel.Tracer("github.com/lincyaw/tools/services/shortcode/internal/service")
+
 const (
 	defaultCodeLength = 6
 	charset           = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	maxRetries        = 5
+	defaultPageSize   = 20
 )
 
 // ShortCodeService short link service interface
 type ShortCodeService interface {
-	CreateShortCode(ctx context.Context, req *model.CreateShortCodeRequest) (*model.CreateShortCodeResponse, error)
-	GetOriginalURL(ctx context.Context, code string) (string, error)
+	CreateShortCode(ctx context.Context, req *model.CreateShortCodeRequest, caller *model.APIKey) (*model.CreateShortCodeResponse, error)
+	CreateShortCodesBatch(ctx context.Context, req *model.BatchCreateShortCodeRequest, caller *model.APIKey) (*model.BatchCreateShortCodeResponse, error)
+	UpdateShortCode(ctx context.Context, code string, req *model.UpdateShortCodeRequest, caller *model.APIKey) (*model.ShortCodeInfo, error)
+	ListShortCodes(ctx context.Context, req *model.ListShortCodesRequest, caller *model.APIKey) (*model.ListShortCodesResponse, error)
+	ResolveRedirect(ctx context.Context, code, password string, bot bool) (*model.RedirectTarget, error)
 	GetStats(ctx context.Context, code string) (*model.ShortCodeStats, error)
-	RecordClick(ctx context.Context, code, ipAddress, userAgent, referer string) error
-	DeleteShortCode(ctx context.Context, code string) error
-	GetMetrics(ctx context.Context) (map[string]interface{}, error)
-	GetDetailedStats(ctx context.Context, code string, hours int) (*model.DetailedStats, error)
+	RecordClicks(ctx context.Context, events []*model.ClickEvent) error
+	DeleteShortCode(ctx context.Context, code string, caller *model.APIKey) error
+	ExpireShortCodes(ctx context.Context, shortCodes []model.ShortCode, mode string) error
+	StreamClicks(ctx context.Context, code string, caller *model.APIKey) (<-chan model.RecordedClick, func(), error)
+	GetMetrics(ctx context.Context) (*model.MetricsSummary, error)
+	GetDetailedStats(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey) (*model.DetailedStats, error)
+	ExportClicks(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey, fn func([]model.ClickExportRow) error) error
+	ExportTimeSeries(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey) ([]model.TimeSeriesItem, error)
 }
 
 type shortCodeService struct {
 	repo    repository.ShortCodeRepository
+	live    repository.LiveClickRepository
+	geo     GeoLocator
+	events  EventPublisher
 	baseURL string
+	links   config.LinkConfig
 }
 
-// NewShortCodeService creates short link service instance
-func NewShortCodeService(repo repository.ShortCodeRepository, baseURL string) ShortCodeService {
+// NewShortCodeService creates short link service instance, events may be nil
+func NewShortCodeService(repo repository.ShortCodeRepository, live repository.LiveClickRepository, geo GeoLocator, events EventPublisher, baseURL string, links config.LinkConfig) ShortCodeService {
 	return &shortCodeService{
 		repo:    repo,
+		live:    live,
+		geo:     geo,
+		events:  events,
 		baseURL: baseURL,
+		links:   links,
 	}
 }
 
-// CreateShortCode creates short link
-func (s *shortCodeService) CreateShortCode(ctx context.Context, req *model.CreateShortCodeRequest) (*model.CreateShortCodeResponse, error) {
-	// Validate URL
-	if !isValidURL(req.URL) {
-		return nil, ErrInvalidURL
-	}
+// CreateShortCode creates short link, owned by the caller if an API key is given
+func (s *shortCodeService) CreateShortCode(ctx context.Context, req *model.CreateShortCodeRequest, caller *model.APIKey) (*model.CreateShortCodeResponse, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.CreateShortCode")
+	defer span.End()
 
-	var code string
-	var err error
+	shortCode, err := s.newShortCode(req, caller)
+	if err != nil {
+		return nil, err
+	}
 
 	// If custom code is provided
-	if req.CustomCode != "" {
-		if !isValidCode(req.CustomCode) {
-			return nil, ErrInvalidCode
-		}
-
-		exists, err := s.repo.CodeExists(ctx, req.CustomCode)
+	if shortCode.Code != "" {
+		exists, err := s.repo.CodeExists(ctx, shortCode.Code)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check code existence: %w", err)
 		}
 		if exists {
 			return nil, ErrCodeExists
 		}
-		code = req.CustomCode
 	} else {
 		// Generate random code
-		code, err = s.generateUniqueCode(ctx)
+		shortCode.Code, err = s.generateUniqueCode(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate unique code: %w", err)
 		}
 	}
 
-	// Calculate expiration time
-	var expiresAt *time.Time
+	if err := s.repo.Create(ctx, shortCode); err != nil {
+		return nil, fmt.Errorf("failed to create short code: %w", err)
+	}
+
+	s.publish(ctx, model.EventLinkCreated, shortCode)
+	return s.toCreateResponse(shortCode), nil
+}
+
+// newShortCode validates a create request and builds the short link, the code is left empty unless a custom code is given
+func (s *shortCodeService) newShortCode(req *model.CreateShortCodeRequest, caller *model.APIKey) (*model.ShortCode, error) {
+	// Validate URL
+	if !isValidURL(req.URL) {
+		return nil, ErrInvalidURL
+	}
+
+	if req.CustomCode != "" && !isValidCode(req.CustomCode) {
+		return nil, ErrInvalidCode
+	}
+
+	if req.RedirectType != 0 && !isValidRedirectType(req.RedirectType) {
+		return nil, ErrInvalidRedirectType
+	}
+
+	if req.MaxClicks < 0 {
+		return nil, ErrInvalidMaxClicks
+	}
+
+	if req.FallbackURL != "" && !isValidURL(req.FallbackURL) {
+		return nil, ErrInvalidFallbackURL
+	}
+
+	// Calculate expiration time, either relative or absolute
+	now := time.Now()
+	expiresAt := req.ExpiresAt
 	if req.ExpiresIn > 0 {
-		expiry := time.Now().Add(time.Duration(req.ExpiresIn) * time.Hour)
+		if expiresAt != nil {
+			return nil, ErrInvalidSchedule
+		}
+		expiry := now.Add(time.Duration(req.ExpiresIn) * time.Hour)
 		expiresAt = &expiry
 	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, ErrInvalidSchedule
+	}
+	if req.ActivatesAt != nil && expiresAt != nil && !req.ActivatesAt.Before(*expiresAt) {
+		return nil, ErrInvalidSchedule
+	}
 
-	// Create short code
 	shortCode := &model.ShortCode{
-		Code:        code,
-		OriginalURL: req.URL,
-		ExpiresAt:   expiresAt,
+		Code:         req.CustomCode,
+		OriginalURL:  req.URL,
+		ExpiresAt:    expiresAt,
+		ActivatesAt:  req.ActivatesAt,
+		RedirectType: req.RedirectType,
+		FallbackURL:  req.FallbackURL,
+	}
+	if caller != nil {
+		shortCode.OwnerID = &caller.ID
+	}
+	if req.MaxClicks > 0 {
+		shortCode.MaxClicks = &req.MaxClicks
 	}
 
-	if err := s.repo.Create(ctx, shortCode); err != nil {
-		return nil, fmt.Errorf("failed to create short code: %w", err)
+	if req.Password != "" {
+		hash, err := hashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		shortCode.PasswordHash = hash
 	}
 
+	return shortCode, nil
+}
+
+// toCreateResponse converts a newly created short link into the create response
+func (s *shortCodeService) toCreateResponse(shortCode *model.ShortCode) *model.CreateShortCodeResponse {
 	return &model.CreateShortCodeResponse{
-		ShortCode:   code,
-		ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, code),
-		OriginalURL: req.URL,
-		CreatedAt:   shortCode.CreatedAt,
-		ExpiresAt:   expiresAt,
-	}, nil
+		ShortCode:         shortCode.Code,
+		ShortURL:          fmt.Sprintf("%s/%s", s.baseURL, shortCode.Code),
+		OriginalURL:       shortCode.OriginalURL,
+		CreatedAt:         shortCode.CreatedAt,
+		ExpiresAt:         shortCode.ExpiresAt,
+		ActivatesAt:       shortCode.ActivatesAt,
+		RedirectType:      s.redirectType(shortCode.RedirectType),
+		PasswordProtected: shortCode.PasswordHash != "",
+		MaxClicks:         shortCode.MaxClicks,
+		FallbackURL:       shortCode.FallbackURL,
+	}
 }
 
-// GetOriginalURL gets original URL
-func (s *shortCodeService) GetOriginalURL(ctx context.Context, code string) (string, error) {
+// UpdateShortCode updates mutable fields of a short link, only the owner or an admin can update it
+func (s *shortCodeService) UpdateShortCode(ctx context.Context, code string, req *model.UpdateShortCodeRequest, caller *model.APIKey) (*model.ShortCodeInfo, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.UpdateShortCode", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
+	current, err := s.authorize(ctx, code, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.URL != nil {
+		if !isValidURL(*req.URL) {
+			return nil, ErrInvalidURL
+		}
+		updates["original_url"] = *req.URL
+	}
+
+	// The merged schedule must hold the same rules as a new link
+	now := time.Now()
+	expiresAt, activatesAt := current.ExpiresAt, current.ActivatesAt
+	if req.ExpiresIn != nil {
+		if req.ExpiresAt != nil {
+			return nil, ErrInvalidSchedule
+		}
+		if *req.ExpiresIn > 0 {
+			expiry := now.Add(time.Duration(*req.ExpiresIn) * time.Hour)
+			expiresAt = &expiry
+			updates["expires_at"] = expiry
+		} else {
+			expiresAt = nil
+			updates["expires_at"] = nil
+		}
+	}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, ErrInvalidSchedule
+		}
+		expiresAt = req.ExpiresAt
+		updates["expires_at"] = *expiresAt
+	}
+	if req.ActivatesAt.Set {
+		activatesAt = req.ActivatesAt.Time
+		if activatesAt != nil {
+			updates["activates_at"] = *activatesAt
+		} else {
+			updates["activates_at"] = nil
+		}
+	}
+	if activatesAt != nil && expiresAt != nil && !activatesAt.Before(*expiresAt) {
+		return nil, ErrInvalidSchedule
+	}
+
+	if req.RedirectType != nil {
+		if *req.RedirectType != 0 && !isValidRedirectType(*req.RedirectType) {
+			return nil, ErrInvalidRedirectType
+		}
+		updates["redirect_type"] = *req.RedirectType
+	}
+
+	if req.Password != nil {
+		if *req.Password == "" {
+			updates["password_hash"] = ""
+		} else {
+			hash, err := hashPassword(*req.Password)
+			if err != nil {
+				return nil, err
+			}
+			updates["password_hash"] = hash
+		}
+	}
+
+	if req.MaxClicks != nil {
+		switch {
+		case *req.MaxClicks < 0:
+			return nil, ErrInvalidMaxClicks
+		case *req.MaxClicks == 0:
+			updates["max_clicks"] = nil
+		default:
+			updates["max_clicks"] = *req.MaxClicks
+		}
+	}
+
+	if req.FallbackURL != nil {
+		if *req.FallbackURL != "" && !isValidURL(*req.FallbackURL) {
+			return nil, ErrInvalidFallbackURL
+		}
+		updates["fallback_url"] = *req.FallbackURL
+	}
+
+	if req.Disabled != nil {
+		updates["disabled"] = *req.Disabled
+	}
+
+	if len(updates) == 0 {
+		return nil, ErrEmptyUpdate
+	}
+
+	shortCode, err := s.repo.UpdateShortCode(ctx, code, updates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update short code: %w", err)
+	}
+
+	s.publish(ctx, model.EventLinkUpdated, shortCode)
+	return s.toInfo(shortCode), nil
+}
+
+// ListShortCodes lists short links page by page, non-admin callers only see their own links
+func (s *shortCodeService) ListShortCodes(ctx context.Context, req *model.ListShortCodesRequest, caller *model.APIKey) (*model.ListShortCodesResponse, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.ListShortCodes")
+	defer span.End()
+
+	if caller == nil {
+		return nil, ErrUnauthorized
+	}
+
+	filter := &model.ShortCodeFilter{
+		OwnerID:       &caller.ID,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Status:        req.Status,
+		HasClicks:     req.HasClicks,
+		URLContains:   req.Query,
+		SortBy:        req.Sort,
+		Ascending:     req.Order == "asc",
+		Limit:         req.Limit,
+	}
+	if caller.IsAdmin {
+		filter.OwnerID = req.OwnerID
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = "created_at"
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil || cursor.SortBy != filter.SortBy {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+
+	// Fetch one extra row to know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+
+	shortCodes, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list short codes: %w", err)
+	}
+
+	resp := &model.ListShortCodesResponse{
+		Items: make([]model.ShortCodeInfo, 0, min(len(shortCodes), pageSize)),
+	}
+
+	if len(shortCodes) > pageSize {
+		shortCodes = shortCodes[:pageSize]
+		last := shortCodes[pageSize-1]
+		resp.HasMore = true
+		resp.NextCursor = encodeCursor(&model.ShortCodeCursor{
+			SortBy:     filter.SortBy,
+			CreatedAt:  last.CreatedAt,
+			ClickCount: last.ClickCount,
+			ID:         last.ID,
+		})
+	}
+
+	for i := range shortCodes {
+		resp.Items = append(resp.Items, *s.toInfo(&shortCodes[i]))
+	}
+
+	return resp, nil
+}
+
+// ResolveRedirect resolves the destination and redirect status of a short link, protected links require the password.
+// Expired, exhausted and disabled links resolve to their fallback URL if one is configured.
+func (s *shortCodeService) ResolveRedirect(ctx context.Context, code, password string, bot bool) (*model.RedirectTarget, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.ResolveRedirect", trace.WithAttributes(attribute.String("shortcode.code", code), attribute.Bool("shortcode.bot", bot)))
+	defer span.End()
+
 	shortCode, err := s.repo.GetByCode(ctx, code)
+	switch {
+	case errors.Is(err, repository.ErrNotYetActive):
+		return nil, ErrNotYetActive
+	case errors.Is(err, repository.ErrExpired):
+		s.reportExpired(ctx, shortCode)
+		return s.fallback(shortCode, ErrLinkExpired, bot)
+	case errors.Is(err, repository.ErrDisabled):
+		return s.fallback(shortCode, ErrLinkDisabled, bot)
+	case err != nil:
+		return nil, ErrCodeNotFound
+	}
+
+	if err := checkPassword(shortCode, password); err != nil {
+		return nil, err
+	}
+
+	target := &model.RedirectTarget{
+		URL:        shortCode.OriginalURL,
+		StatusCode: s.redirectType(shortCode.RedirectType),
+		Bot:        bot,
+	}
+
+	// Bots do not use up click-limited links, so an unfurled one-time link still works for its recipient
+	if bot {
+		if remaining := remainingClicks(shortCode.MaxClicks, shortCode.ClickCount); remaining != nil && *remaining == 0 {
+			return s.fallback(shortCode, ErrLinkExhausted, bot)
+		}
+		return target, nil
+	}
+
+	// Click-limited links count the click before redirecting, the cached row may be stale
+	if shortCode.MaxClicks != nil {
+		claimed, exhausted, err := s.repo.ClaimClick(ctx, shortCode.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim click: %w", err)
+		}
+		if !claimed {
+			return s.fallback(shortCode, ErrLinkExhausted, bot)
+		}
+		target.Counted = true
+
+		// The redirect using the last click reports the exhausted link, later ones fall back silently
+		if exhausted {
+			shortCode.ClickCount = *shortCode.MaxClicks
+			s.publish(ctx, model.EventLinkExpired, shortCode)
+		}
+	}
+
+	return target, nil
+}
+
+// reportExpired sends link.expired for an expired link unless a redirect or the sweeper already did for this expiry
+func (s *shortCodeService) reportExpired(ctx context.Context, shortCode *model.ShortCode) {
+	if s.events == nil {
+		return
+	}
+
+	claimed, err := s.repo.ClaimExpiryReport(ctx, []uint{shortCode.ID}, time.Now())
 	if err != nil {
-		return "", ErrCodeNotFound
+		slog.WarnContext(ctx, "Failed to claim expiry report", "error", err)
+		return
+	}
+	if len(claimed) > 0 {
+		s.publish(ctx, model.EventLinkExpired, shortCode)
+	}
+}
+
+// fallback redirects a link that is no longer live to its fallback URL or the service default, otherwise returns reason
+func (s *shortCodeService) fallback(shortCode *model.ShortCode, reason error, bot bool) (*model.RedirectTarget, error) {
+	fallbackURL := shortCode.FallbackURL
+	if fallbackURL == "" {
+		fallbackURL = s.links.DefaultFallbackURL
+	}
+	if fallbackURL == "" {
+		return nil, reason
 	}
 
-	return shortCode.OriginalURL, nil
+	// Always temporary, browsers must not remember the fallback if the link is re-enabled
+	return &model.RedirectTarget{
+		URL:        fallbackURL,
+		StatusCode: http.StatusFound,
+		Fallback:   true,
+		Bot:        bot,
+	}, nil
 }
 
 // GetStats gets statistics
 func (s *shortCodeService) GetStats(ctx context.Context, code string) (*model.ShortCodeStats, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.GetStats", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
 	stats, err := s.repo.GetStats(ctx, code)
 	if err != nil {
 		return nil, ErrCodeNotFound
 	}
+	stats.RedirectType = s.redirectType(stats.RedirectType)
+	stats.RemainingClicks = remainingClicks(stats.MaxClicks, stats.ClickCount)
 	return stats, nil
 }
 
-// RecordClick records click
-func (s *shortCodeService) RecordClick(ctx context.Context, code, ipAddress, userAgent, referer string) error {
-	shortCode, err := s.repo.GetByCode(ctx, code)
+// DeleteShortCode deletes short link, only the owner or an admin can delete it
+func (s *shortCodeService) DeleteShortCode(ctx context.Context, code string, caller *model.APIKey) error {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.DeleteShortCode", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
+	shortCode, err := s.authorize(ctx, code, caller)
 	if err != nil {
-		return fmt.Errorf("failed to get short code: %w", err)
+		return err
 	}
 
-	// Update click count
-	if err := s.repo.UpdateClickCount(ctx, shortCode.ID); err != nil {
-		return fmt.Errorf("failed to update click count: %w", err)
+	if err := s.repo.Delete(ctx, code); err != nil {
+		return ErrCodeNotFound
 	}
 
-	// Record click log
-	log := &model.ClickLog{
-		ShortCodeID: shortCode.ID,
-		IPAddress:   ipAddress,
-		UserAgent:   userAgent,
-		Referer:     referer,
-	}
+	s.publish(ctx, model.EventLinkDeleted, shortCode)
+	return nil
+}
 
-	if err := s.repo.LogClick(ctx, log); err != nil {
-		return fmt.Errorf("failed to log click: %w", err)
+// ExpireShortCodes handles expired links found by the expiry sweeper: removes them according to the mode
+// (none, soft_delete or archive), evicts their cache and reports link.expired once per expiry if no redirect
+// reported it first
+func (s *shortCodeService) ExpireShortCodes(ctx context.Context, shortCodes []model.ShortCode, mode string) error {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.ExpireShortCodes", trace.WithAttributes(attribute.Int("shortcode.links", len(shortCodes))))
+	defer span.End()
+
+	codes := make([]string, len(shortCodes))
+	ids := make([]uint, len(shortCodes))
+	for i, shortCode := range shortCodes {
+		codes[i] = shortCode.Code
+		ids[i] = shortCode.ID
 	}
 
-	// Get IP location information
-	location := s.getIPLocation(ipAddress)
+	switch mode {
+	case "soft_delete":
+		if err := s.repo.DeleteByIDs(ctx, ids); err != nil {
+			return err
+		}
+	case "archive":
+		if err := s.repo.Archive(ctx, shortCodes); err != nil {
+			return err
+		}
+	}
 
-	// Record access statistics with hourly bucket
-	hourBucket := time.Now().Truncate(time.Hour)
-	stats := &model.AccessStatistics{
-		ShortCodeID: shortCode.ID,
-		IPAddress:   ipAddress,
-		Country:     location.Country,
-		Region:      location.Region,
-		City:        location.City,
-		HourBucket:  hourBucket,
+	// Only the sweeper that marks a link handles it, others running concurrently skip it
+	now := time.Now()
+	handled, err := s.repo.ClaimExpired(ctx, ids, now)
+	if err != nil {
+		return err
+	}
+	claimed, err := s.repo.ClaimExpiryReport(ctx, handled, now)
+	if err != nil {
+		return err
 	}
 
-	if err := s.repo.RecordAccessStats(ctx, stats); err != nil {
-		return fmt.Errorf("failed to record access stats: %w", err)
+	// Evict after removing, so a concurrent redirect cannot re-cache the row
+	if err := s.repo.InvalidateCaches(ctx, codes); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate cache of expired links", "error", err)
 	}
 
+	expired := make(map[uint]bool, len(claimed))
+	for _, id := range claimed {
+		expired[id] = true
+	}
+	for i := range shortCodes {
+		if expired[shortCodes[i].ID] {
+			s.publish(ctx, model.EventLinkExpired, &shortCodes[i])
+		}
+	}
 	return nil
 }
 
-// DeleteShortCode deletes short link
-func (s *shortCodeService) DeleteShortCode(ctx context.Context, code string) error {
-	return s.repo.Delete(ctx, code)
+// StreamClicks subscribes to the clicks of a short link as they are recorded, or to the clicks of all
+// links of the caller for an empty code. Only the owner or an admin can follow a link. The channel is
+// closed by the returned stop function or when the stream shuts down.
+func (s *shortCodeService) StreamClicks(ctx context.Context, code string, caller *model.APIKey) (<-chan model.RecordedClick, func(), error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.StreamClicks", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
+
+	var subscription *repository.LiveSubscription
+	var err error
+	if code != "" {
+		if _, err := s.authorize(ctx, code, caller); err != nil {
+			return nil, nil, err
+		}
+		subscription, err = s.live.SubscribeCode(ctx, code)
+	} else {
+		if caller == nil {
+			return nil, nil, ErrUnauthorized
+		}
+		subscription, err = s.live.SubscribeOwner(ctx, caller.ID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe to clicks: %w", err)
+	}
+	return subscription.C, subscription.Close, nil
 }
 
 // GetMetrics gets service metrics
-func (s *shortCodeService) GetMetrics(ctx context.Context) (map[string]interface{}, error) {
+func (s *shortCodeService) GetMetrics(ctx context.Context) (*model.MetricsSummary, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.GetMetrics")
+	defer span.End()
+
 	return s.repo.GetMetrics(ctx)
 }
 
+// toInfo converts a short link into its API representation
+func (s *shortCodeService) toInfo(shortCode *model.ShortCode) *model.ShortCodeInfo {
+	return &model.ShortCodeInfo{
+		Code:              shortCode.Code,
+		ShortURL:          fmt.Sprintf("%s/%s", s.baseURL, shortCode.Code),
+		OriginalURL:       shortCode.OriginalURL,
+		CreatedAt:         shortCode.CreatedAt,
+		UpdatedAt:         shortCode.UpdatedAt,
+		ExpiresAt:         shortCode.ExpiresAt,
+		ActivatesAt:       shortCode.ActivatesAt,
+		ClickCount:        shortCode.ClickCount,
+		LastAccessedAt:    shortCode.LastAccessedAt,
+		OwnerID:           shortCode.OwnerID,
+		RedirectType:      s.redirectType(shortCode.RedirectType),
+		PasswordProtected: shortCode.PasswordHash != "",
+		MaxClicks:         shortCode.MaxClicks,
+		RemainingClicks:   remainingClicks(shortCode.MaxClicks, shortCode.ClickCount),
+		FallbackURL:       shortCode.FallbackURL,
+		FallbackCount:     shortCode.FallbackCount,
+		Disabled:          shortCode.Disabled,
+	}
+}
+
+// publish reports a lifecycle event of a short link to the event publisher, if any
+func (s *shortCodeService) publish(ctx context.Context, eventType string, shortCode *model.ShortCode) {
+	if s.events == nil {
+		return
+	}
+	s.events.PublishLinkEvent(ctx, eventType, s.toInfo(shortCode))
+}
+
+// remainingClicks returns the redirects left before a click-limited link is exhausted, nil for unlimited links
+func remainingClicks(maxClicks *int64, clickCount int64) *int64 {
+	if maxClicks == nil {
+		return nil
+	}
+	remaining := max(*maxClicks-clickCount, 0)
+	return &remaining
+}
+
+// redirectType returns the effective redirect status, falling back to the service default
+func (s *shortCodeService) redirectType(redirectType int) int {
+	if redirectType == 0 {
+		return s.links.DefaultRedirectType
+	}
+	return redirectType
+}
+
+// authorize loads the short link and checks that the caller can manage it
+func (s *shortCodeService) authorize(ctx context.Context, code string, caller *model.APIKey) (*model.ShortCode, error) {
+	if caller == nil {
+		return nil, ErrUnauthorized
+	}
+
+	shortCode, err := s.repo.FindByCode(ctx, code)
+	if err != nil {
+		return nil, ErrCodeNotFound
+	}
+
+	if err := canManage(caller, shortCode); err != nil {
+		return nil, err
+	}
+	return shortCode, nil
+}
+
 // generateUniqueCode generates unique code
 func (s *shortCodeService) generateUniqueCode(ctx context.Context) (string, error) {
 	for i := 0; i < maxRetries; i++ {
@@ -209,6 +699,26 @@ func (s *shortCodeService) generateUniqueCode(ctx context.Context) (string, erro
 	return "", errors.New("failed to generate unique code after max retries")
 }
 
+// encodeCursor encodes a pagination cursor as an opaque string
+func encodeCursor(cursor *model.ShortCodeCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a pagination cursor created by encodeCursor
+func decodeCursor(raw string) (*model.ShortCodeCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor model.ShortCodeCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
 // generateRandomCode generates random code
 func generateRandomCode(length int) string {
 	code := make([]byte, length)
@@ -231,6 +741,15 @@ func isValidURL(rawURL string) bool {
 	return u.Scheme == "http" || u.Scheme == "https"
 }
 
+// isValidRedirectType validates if the status code is a supported redirect
+func isValidRedirectType(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
 // isValidCode validates if code format is valid
 func isValidCode(code string) bool {
 	// Code can only contain letters and numbers, length between 4-50
@@ -238,101 +757,43 @@ func isValidCode(code string) bool {
 	return re.MatchString(code)
 }
 
-// GetDetailedStats gets detailed statistics
-func (s *shortCodeService) GetDetailedStats(ctx context.Context, code string, hours int) (*model.DetailedStats, error) {
-	stats, err := s.repo.GetDetailedStats(ctx, code, hours)
-	if err != nil {
-		return nil, ErrCodeNotFound
-	}
-	return stats, nil
-}
-
-// getIPLocation gets IP location information
-func (s *shortCodeService) getIPLocation(ipAddress string) model.IPLocation {
-	// Default location
-	location := model.IPLocation{
-		Country: "Unknown",
-		Region:  "Unknown",
-		City:    "Unknown",
-	}
-
-	// Skip for local/private IPs
-	if isPrivateIP(ipAddress) {
-		location.Country = "Private"
-		location.Region = "Local"
-		location.City = "Local"
-		return location
-	}
-
-	// Use ip-api.com free API (limited to 45 requests per minute)
-	// In production, consider using a paid service or caching
-	apiURL := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,country,regionName,city", ipAddress)
+// GetDetailedStats gets detailed statistics, only the owner or an admin can see them
+func (s *shortCodeService) GetDetailedStats(ctx context.Context, code string, query *model.StatsQuery, caller *model.APIKey) (*model.DetailedStats, error) {
+	ctx, span := tracer.Start(ctx, "ShortCodeService.GetDetailedStats", trace.WithAttributes(attribute.String("shortcode.code", code)))
+	defer span.End()
 
-	client := &http.Client{
-		Timeout: 2 * time.Second,
-	}
-
-	resp, err := client.Get(apiURL)
+	shortCode, err := s.authorize(ctx, code, caller)
 	if err != nil {
-		return location
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return location
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	filter, err := normalizeStatsQuery(query)
 	if err != nil {
-		return location
+		return nil, err
 	}
-
-	var result struct {
-		Status     string `json:"status"`
-		Country    string `json:"country"`
-		RegionName string `json:"regionName"`
-		City       string `json:"city"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return location
-	}
-
-	if result.Status == "success" {
-		if result.Country != "" {
-			location.Country = result.Country
-		}
-		if result.RegionName != "" {
-			location.Region = result.RegionName
-		}
-		if result.City != "" {
-			location.City = result.City
-		}
+	buckets, err := timeSeriesBuckets(filter, shortCode.CreatedAt, maxTimeSeriesBuckets)
+	if err != nil {
+		return nil, err
 	}
 
-	return location
-}
-
-// isPrivateIP checks if IP is private/local
-func isPrivateIP(ip string) bool {
-	// Simple check for common private IP ranges and localhost
-	if ip == "" || ip == "::1" || ip == "localhost" {
-		return true
+	stats, err := s.repo.GetDetailedStats(ctx, code, filter)
+	if err != nil {
+		return nil, ErrCodeNotFound
 	}
-
-	// Check for private IPv4 ranges
-	privateRanges := []string{
-		"10.", "172.16.", "172.17.", "172.18.", "172.19.",
-		"172.20.", "172.21.", "172.22.", "172.23.", "172.24.",
-		"172.25.", "172.26.", "172.27.", "172.28.", "172.29.",
-		"172.30.", "172.31.", "192.168.", "127.",
+	stats.RedirectType = s.redirectType(stats.RedirectType)
+	stats.RemainingClicks = remainingClicks(stats.MaxClicks, stats.TotalClicks)
+	stats.Granularity = filter.Granularity
+	stats.Timezone = filter.Location.String()
+
+	// Only the buckets of the series are read, the default range can be longer than the series
+	series := *filter
+	if len(buckets) > 0 {
+		series.From = buckets[0]
 	}
-
-	for _, prefix := range privateRanges {
-		if len(ip) >= len(prefix) && ip[:len(prefix)] == prefix {
-			return true
-		}
+	items, err := s.repo.GetTimeSeries(ctx, code, &series)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time series: %w", err)
 	}
-
-	return false
+	stats.TimeSeries = fillTimeSeries(buckets, items)
+	return stats, nil
 }
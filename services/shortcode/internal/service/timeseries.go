@@ -0,0 +1,122 @@
+package service
+
+import (
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// maxTimeSeriesBuckets most buckets of a time series in detailed statistics, maxExportBuckets of an
+// exported one. Explicit ranges with more buckets are rejected, the default range since link creation
+// is cut to the latest buckets.
+const (
+	maxTimeSeriesBuckets = 1000
+	maxExportBuckets     = 100000
+)
+
+// normalizeStatsQuery validates the statistics filters and fills in the defaults: hourly buckets
+// in UTC up to now
+func normalizeStatsQuery(query *model.StatsQuery) (*model.StatsQuery, error) {
+	filter := *query
+	if filter.Granularity == "" {
+		filter.Granularity = model.GranularityHour
+	}
+	switch filter.Granularity {
+	case model.GranularityMinute, model.GranularityHour, model.GranularityDay, model.GranularityWeek, model.GranularityMonth:
+	default:
+		return nil, ErrInvalidGranularity
+	}
+
+	if filter.Location == nil {
+		filter.Location = time.UTC
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	if !filter.From.IsZero() && !filter.From.Before(filter.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	return &filter, nil
+}
+
+// timeSeriesBuckets lists the bucket starts of the series, from the bucket holding From (or the
+// creation of the link) to the last bucket starting before To, at most limit buckets
+func timeSeriesBuckets(filter *model.StatsQuery, createdAt time.Time, limit int) ([]time.Time, error) {
+	last := bucketStart(filter.To.Add(-time.Nanosecond), filter.Granularity, filter.Location)
+
+	var start time.Time
+	if !filter.From.IsZero() {
+		start = bucketStart(filter.From, filter.Granularity, filter.Location)
+	} else {
+		// Walk back from the end, at most to the bucket the link was created in
+		first := bucketStart(createdAt, filter.Granularity, filter.Location)
+		start = last
+		for n := 1; n < limit && start.After(first); n++ {
+			start = bucketStart(start.Add(-time.Nanosecond), filter.Granularity, filter.Location)
+		}
+	}
+
+	var buckets []time.Time
+	for bucket := start; !bucket.After(last); bucket = nextBucket(bucket, filter.Granularity, filter.Location) {
+		if len(buckets) == limit {
+			return nil, ErrTooManyBuckets
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// bucketStart returns the start of the bucket holding t, in the time zone of the series
+func bucketStart(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch granularity {
+	case model.GranularityMinute, model.GranularityHour:
+		unit := time.Minute
+		if granularity == model.GranularityHour {
+			unit = time.Hour
+		}
+		// Truncate works on absolute time, shift by the zone offset to align to local minutes and hours
+		_, offset := t.Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(unit).Add(-shift)
+	case model.GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case model.GranularityWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at bucket
+func nextBucket(bucket time.Time, granularity string, loc *time.Location) time.Time {
+	switch granularity {
+	case model.GranularityMinute:
+		return bucketStart(bucket.Add(time.Minute), granularity, loc)
+	case model.GranularityHour:
+		return bucketStart(bucket.Add(time.Hour), granularity, loc)
+	case model.GranularityDay:
+		return time.Date(bucket.Year(), bucket.Month(), bucket.Day()+1, 0, 0, 0, 0, loc)
+	case model.GranularityWeek:
+		return time.Date(bucket.Year(), bucket.Month(), bucket.Day()+7, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(bucket.Year(), bucket.Month()+1, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// fillTimeSeries lays the buckets with clicks over all buckets of the series, the others count zero
+func fillTimeSeries(buckets []time.Time, items []model.TimeSeriesItem) []model.TimeSeriesItem {
+	counted := make(map[int64]model.TimeSeriesItem, len(items))
+	for _, item := range items {
+		counted[item.Bucket.Unix()] = item
+	}
+
+	series := make([]model.TimeSeriesItem, 0, len(buckets))
+	for _, bucket := range buckets {
+		item := counted[bucket.Unix()]
+		item.Bucket = bucket
+		series = append(series, item)
+	}
+	return series
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+)
+
+var (
+	// ErrWebhookNotFound webhook not found
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidWebhookURL webhook URL that is not http(s) or points to a private address
+	ErrInvalidWebhookURL = errors.New("invalid webhook URL")
+	// ErrInvalidEventType unknown webhook event type
+	ErrInvalidEventType = errors.New("invalid event type")
+	// ErrTooManyWebhooks the API key has registered the maximum number of webhooks
+	ErrTooManyWebhooks = errors.New("too many webhooks")
+	// ErrDeadLetterNotFound dead letter not found or already replayed
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
+
+const (
+	webhookSecretPrefix = "whsec_"
+	webhookSecretLength = 32
+	webhookEventPrefix  = "evt_"
+	webhookEventLength  = 24
+	// maxWebhooksPerKey webhooks an API key can register, global webhooks are not limited
+	maxWebhooksPerKey      = 10
+	defaultDeliveryPerPage = 50
+)
+
+// EventPublisher receives the lifecycle and click events of short links, e.g. to send webhooks.
+// Publishing is best effort and never fails the operation that caused the event.
+type EventPublisher interface {
+	PublishLinkEvent(ctx context.Context, eventType string, link *model.ShortCodeInfo)
+	PublishClicks(ctx context.Context, clicks []model.RecordedClick)
+}
+
+// WebhookService webhook service interface
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, req *model.CreateWebhookRequest, caller *model.APIKey) (*model.CreateWebhookResponse, error)
+	ListWebhooks(ctx context.Context, caller *model.APIKey) ([]model.WebhookInfo, error)
+	GetWebhook(ctx context.Context, id uint, caller *model.APIKey) (*model.WebhookInfo, error)
+	UpdateWebhook(ctx context.Context, id uint, req *model.UpdateWebhookRequest, caller *model.APIKey) (*model.WebhookInfo, error)
+	DeleteWebhook(ctx context.Context, id uint, caller *model.APIKey) error
+	ListDeliveries(ctx context.Context, id uint, req *model.ListWebhookDeliveriesRequest, caller *model.APIKey) (*model.ListWebhookDeliveriesResponse, error)
+	ListDeadLetters(ctx context.Context, id uint, req *model.ListWebhookDeadLettersRequest, caller *model.APIKey) (*model.ListWebhookDeadLettersResponse, error)
+	ReplayDeadLetter(ctx context.Context, id, letterID uint, caller *model.APIKey) (*model.WebhookDelivery, error)
+	DispatchLinkEvents(ctx context.Context, events []model.LinkEvent) error
+	DispatchClicks(ctx context.Context, clicks []model.RecordedClick) error
+}
+
+type webhookService struct {
+	repo repository.WebhookRepository
+	cfg  config.WebhookConfig
+}
+
+// NewWebhookService creates webhook service instance
+func NewWebhookService(repo repository.WebhookRepository, cfg config.WebhookConfig) WebhookService {
+	return &webhookService{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// CreateWebhook registers a webhook for the links of the caller, or for all links if an admin asks for a global one
+func (s *webhookService) CreateWebhook(ctx context.Context, req *model.CreateWebhookRequest, caller *model.APIKey) (*model.CreateWebhookResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+
+	if caller == nil {
+		return nil, ErrUnauthorized
+	}
+	if req.Global && !caller.IsAdmin {
+		return nil, ErrForbidden
+	}
+	if err := s.validateURL(req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeEventTypes(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &model.Webhook{
+		URL:         req.URL,
+		Events:      events,
+		Secret:      webhookSecretPrefix + generateRandomCode(webhookSecretLength),
+		Description: req.Description,
+	}
+	if !req.Global {
+		count, err := s.repo.CountByOwner(ctx, caller.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count webhooks: %w", err)
+		}
+		if count >= maxWebhooksPerKey {
+			return nil, ErrTooManyWebhooks
+		}
+		webhook.OwnerID = &caller.ID
+	}
+
+	if err := s.repo.Create(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return &model.CreateWebhookResponse{
+		WebhookInfo: *toWebhookInfo(webhook),
+		Secret:      webhook.Secret,
+	}, nil
+}
+
+// ListWebhooks lists the webhooks of the caller, admins see all webhooks
+func (s *webhookService) ListWebhooks(ctx context.Context, caller *model.APIKey) ([]model.WebhookInfo, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListWebhooks")
+	defer span.End()
+
+	if caller == nil {
+		return nil, ErrUnauthorized
+	}
+
+	var ownerID *uint
+	if !caller.IsAdmin {
+		ownerID = &caller.ID
+	}
+	webhooks, err := s.repo.List(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	items := make([]model.WebhookInfo, len(webhooks))
+	for i := range webhooks {
+		items[i] = *toWebhookInfo(&webhooks[i])
+	}
+	return items, nil
+}
+
+// GetWebhook gets a webhook, only its owner or an admin can see it
+func (s *webhookService) GetWebhook(ctx context.Context, id uint, caller *model.APIKey) (*model.WebhookInfo, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhook")
+	defer span.End()
+
+	webhook, err := s.authorizeWebhook(ctx, id, caller)
+	if err != nil {
+		return nil, err
+	}
+	return toWebhookInfo(webhook), nil
+}
+
+// UpdateWebhook updates the URL, events, description or disabled flag of a webhook
+func (s *webhookService) UpdateWebhook(ctx context.Context, id uint, req *model.UpdateWebhookRequest, caller *model.APIKey) (*model.WebhookInfo, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.UpdateWebhook")
+	defer span.End()
+
+	if _, err := s.authorizeWebhook(ctx, id, caller); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.URL != nil {
+		if err := s.validateURL(*req.URL); err != nil {
+			return nil, err
+		}
+		updates["url"] = *req.URL
+	}
+
+	if req.Events != nil {
+		events, err := normalizeEventTypes(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		updates["events"] = events
+	}
+
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if req.Disabled != nil {
+		updates["disabled"] = *req.Disabled
+	}
+
+	if len(updates) == 0 {
+		return nil, ErrEmptyUpdate
+	}
+
+	webhook, err := s.repo.Update(ctx, id, updates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return toWebhookInfo(webhook), nil
+}
+
+// DeleteWebhook deletes a webhook, its pending deliveries are dropped
+func (s *webhookService) DeleteWebhook(ctx context.Context, id uint, caller *model.APIKey) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+
+	if _, err := s.authorizeWebhook(ctx, id, caller); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries lists the delivery log of a webhook page by page, newest first
+func (s *webhookService) ListDeliveries(ctx context.Context, id uint, req *model.ListWebhookDeliveriesRequest, caller *model.APIKey) (*model.ListWebhookDeliveriesResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListDeliveries")
+	defer span.End()
+
+	if _, err := s.authorizeWebhook(ctx, id, caller); err != nil {
+		return nil, err
+	}
+
+	filter := &model.WebhookDeliveryFilter{
+		WebhookID: id,
+		Status:    req.Status,
+		EventType: req.EventType,
+		Limit:     req.Limit,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeliveryPerPage
+	}
+	if req.Cursor != "" {
+		beforeID, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter.BeforeID = uint(beforeID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+
+	deliveries, err := s.repo.ListDeliveries(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+
+	resp := &model.ListWebhookDeliveriesResponse{
+		Items: make([]model.WebhookDeliveryInfo, 0, min(len(deliveries), pageSize)),
+	}
+	if len(deliveries) > pageSize {
+		deliveries = deliveries[:pageSize]
+		resp.HasMore = true
+		resp.NextCursor = strconv.FormatUint(uint64(deliveries[pageSize-1].ID), 10)
+	}
+	for _, delivery := range deliveries {
+		resp.Items = append(resp.Items, model.WebhookDeliveryInfo{
+			WebhookDelivery: delivery,
+			Payload:         json.RawMessage(delivery.Payload),
+		})
+	}
+	return resp, nil
+}
+
+// ListDeadLetters lists the deliveries of a webhook that failed on every attempt, newest first
+func (s *webhookService) ListDeadLetters(ctx context.Context, id uint, req *model.ListWebhookDeadLettersRequest, caller *model.APIKey) (*model.ListWebhookDeadLettersResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListDeadLetters")
+	defer span.End()
+
+	if _, err := s.authorizeWebhook(ctx, id, caller); err != nil {
+		return nil, err
+	}
+
+	pageSize := req.Limit
+	if pageSize <= 0 {
+		pageSize = defaultDeliveryPerPage
+	}
+	var beforeID uint
+	if req.Cursor != "" {
+		parsed, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		beforeID = uint(parsed)
+	}
+
+	letters, err := s.repo.ListDeadLetters(ctx, id, beforeID, pageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	resp := &model.ListWebhookDeadLettersResponse{
+		Items: make([]model.WebhookDeadLetterInfo, 0, min(len(letters), pageSize)),
+	}
+	if len(letters) > pageSize {
+		letters = letters[:pageSize]
+		resp.HasMore = true
+		resp.NextCursor = strconv.FormatUint(uint64(letters[pageSize-1].ID), 10)
+	}
+	for _, letter := range letters {
+		resp.Items = append(resp.Items, model.WebhookDeadLetterInfo{
+			WebhookDeadLetter: letter,
+			Payload:           json.RawMessage(letter.Payload),
+		})
+	}
+	return resp, nil
+}
+
+// ReplayDeadLetter queues a dead letter for delivery again, with a fresh set of attempts
+func (s *webhookService) ReplayDeadLetter(ctx context.Context, id, letterID uint, caller *model.APIKey) (*model.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ReplayDeadLetter")
+	defer span.End()
+
+	if _, err := s.authorizeWebhook(ctx, id, caller); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repo.ReplayDeadLetter(ctx, id, letterID, time.Now())
+	if errors.Is(err, repository.ErrDeadLetterNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay dead letter: %w", err)
+	}
+	return delivery, nil
+}
+
+// DispatchLinkEvents queues a delivery of each link lifecycle event to every webhook subscribed to it:
+// the global webhooks and those of the link owner. The webhooks are loaded once and the deliveries
+// of all events are inserted together.
+func (s *webhookService) DispatchLinkEvents(ctx context.Context, events []model.LinkEvent) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DispatchLinkEvents", trace.WithAttributes(attribute.Int("shortcode.events", len(events))))
+	defer span.End()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	webhooks, err := s.repo.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	var deliveries []*model.WebhookDelivery
+	for _, linkEvent := range events {
+		event := newEvent(linkEvent.Type, &model.WebhookLinkData{Link: linkEvent.Link}, linkEvent.Link.OwnerID)
+
+		var payload []byte
+		for _, webhook := range webhooks {
+			if !subscribes(&webhook, event.Type) || !watches(&webhook, event.OwnerID) {
+				continue
+			}
+			if payload == nil {
+				if payload, err = json.Marshal(event); err != nil {
+					return fmt.Errorf("failed to encode event: %w", err)
+				}
+			}
+			deliveries = append(deliveries, newDelivery(webhook.ID, event, payload))
+		}
+	}
+	return s.repo.CreateDeliveries(ctx, deliveries)
+}
+
+// DispatchClicks queues link.clicked events: each webhook gets the clicks of the links it watches,
+// in events of at most the click batch size
+func (s *webhookService) DispatchClicks(ctx context.Context, clicks []model.RecordedClick) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DispatchClicks")
+	defer span.End()
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	webhooks, err := s.repo.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	var deliveries []*model.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !subscribes(&webhook, model.EventLinkClicked) {
+			continue
+		}
+
+		watched := clicks
+		if webhook.OwnerID != nil {
+			watched = nil
+			for _, click := range clicks {
+				if click.OwnerID != nil && *click.OwnerID == *webhook.OwnerID {
+					watched = append(watched, click)
+				}
+			}
+		}
+
+		for chunk := range slices.Chunk(watched, s.cfg.ClickBatchSize) {
+			event := newEvent(model.EventLinkClicked, &model.WebhookClickData{Clicks: chunk}, webhook.OwnerID)
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to encode event: %w", err)
+			}
+			deliveries = append(deliveries, newDelivery(webhook.ID, event, payload))
+		}
+	}
+	return s.repo.CreateDeliveries(ctx, deliveries)
+}
+
+// authorizeWebhook loads a webhook and checks that the caller owns it or is an admin, global webhooks are admin only
+func (s *webhookService) authorizeWebhook(ctx context.Context, id uint, caller *model.APIKey) (*model.Webhook, error) {
+	if caller == nil {
+		return nil, ErrUnauthorized
+	}
+
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if !caller.IsAdmin && (webhook.OwnerID == nil || *webhook.OwnerID != caller.ID) {
+		return nil, ErrForbidden
+	}
+	return webhook, nil
+}
+
+// validateURL checks that the webhook URL is http(s) and, unless allowed, not a private address.
+// Host names are checked again when the delivery connects.
+func (s *webhookService) validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+	if s.cfg.AllowPrivateTargets {
+		return nil
+	}
+	if _, ok := parseIP(u.Hostname()); ok && !IsPublicIP(u.Hostname()) {
+		return ErrInvalidWebhookURL
+	}
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+// normalizeEventTypes validates the event types of a webhook and joins them for storage, empty subscribes to all events
+func normalizeEventTypes(events []string) (string, error) {
+	if len(events) == 0 || slices.Contains(events, model.EventAll) {
+		return model.EventAll, nil
+	}
+
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		if !slices.Contains(model.WebhookEventTypes, event) {
+			return "", ErrInvalidEventType
+		}
+		if !slices.Contains(normalized, event) {
+			normalized = append(normalized, event)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// subscribes reports whether the webhook receives events of the given type
+func subscribes(webhook *model.Webhook, eventType string) bool {
+	if webhook.Events == model.EventAll {
+		return true
+	}
+	return slices.Contains(strings.Split(webhook.Events, ","), eventType)
+}
+
+// watches reports whether a webhook receives the events of links of the owner: global webhooks watch all links
+func watches(webhook *model.Webhook, ownerID *uint) bool {
+	return webhook.OwnerID == nil || (ownerID != nil && *webhook.OwnerID == *ownerID)
+}
+
+// newEvent creates a webhook event with a new event ID
+func newEvent(eventType string, data interface{}, ownerID *uint) *model.WebhookEvent {
+	return &model.WebhookEvent{
+		ID:        webhookEventPrefix + generateRandomCode(webhookEventLength),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+		OwnerID:   ownerID,
+	}
+}
+
+// newDelivery creates the pending delivery of an event to a webhook, due immediately
+func newDelivery(webhookID uint, event *model.WebhookEvent, payload []byte) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       string(payload),
+		Status:        model.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+}
+
+// toWebhookInfo converts a webhook into its API representation
+func toWebhookInfo(webhook *model.Webhook) *model.WebhookInfo {
+	return &model.WebhookInfo{
+		ID:          webhook.ID,
+		OwnerID:     webhook.OwnerID,
+		Global:      webhook.OwnerID == nil,
+		URL:         webhook.URL,
+		Events:      strings.Split(webhook.Events, ","),
+		Description: webhook.Description,
+		Disabled:    webhook.Disabled,
+		CreatedAt:   webhook.CreatedAt,
+		UpdatedAt:   webhook.UpdatedAt,
+	}
+}
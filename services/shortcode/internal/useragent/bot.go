@@ -0,0 +1,104 @@
+package useragent
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+)
+
+// DefaultBotSignatures case-insensitive user agent substrings of crawlers, link unfurlers and HTTP libraries
+var DefaultBotSignatures = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "facebookcatalog", "embedly",
+	"whatsapp", "skypeuripreview", "preview", "unfurl", "headlesschrome", "phantomjs",
+	"curl/", "wget/", "python-requests", "python-urllib", "aiohttp", "go-http-client",
+	"java/", "okhttp", "axios/", "node-fetch", "libwww-perl", "httpclient",
+}
+
+// BotDetector tells crawler and link unfurler requests apart from human clicks
+type BotDetector struct {
+	signatures []string // Lower case
+}
+
+// NewBotDetector creates bot detector matching the given user agent signatures
+func NewBotDetector(signatures []string) *BotDetector {
+	lower := make([]string, 0, len(signatures))
+	for _, signature := range signatures {
+		if signature = strings.ToLower(strings.TrimSpace(signature)); signature != "" {
+			lower = append(lower, signature)
+		}
+	}
+	return &BotDetector{signatures: lower}
+}
+
+// LoadBotDetector creates bot detector from the configuration. A signatures file replaces
+// the default signatures, the extra signatures are added to either.
+func LoadBotDetector(cfg config.BotConfig) (*BotDetector, error) {
+	signatures := DefaultBotSignatures
+	if cfg.SignaturesFile != "" {
+		loaded, err := readSignatures(cfg.SignaturesFile)
+		if err != nil {
+			return nil, err
+		}
+		signatures = loaded
+	}
+
+	return NewBotDetector(append(append([]string{}, signatures...), cfg.ExtraSignatures...)), nil
+}
+
+// IsBot reports whether a redirect request comes from a bot, judging by the user agent and by
+// what browsers always do: follow links with GET and send an Accept header. Prefetches are
+// treated as bots, they are not clicks.
+func (d *BotDetector) IsBot(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return true
+	}
+	if r.Header.Get("Accept") == "" {
+		return true
+	}
+	if purpose := r.Header.Get("Sec-Purpose") + r.Header.Get("Purpose"); strings.Contains(purpose, "prefetch") {
+		return true
+	}
+	return d.MatchUserAgent(r.UserAgent())
+}
+
+// MatchUserAgent reports whether the user agent is empty or contains a bot signature
+func (d *BotDetector) MatchUserAgent(userAgent string) bool {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if userAgent == "" {
+		return true
+	}
+
+	for _, signature := range d.signatures {
+		if strings.Contains(userAgent, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSignatures reads one signature per line, blank lines and lines starting with # are skipped
+func readSignatures(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bot signatures: %w", err)
+	}
+	defer file.Close()
+
+	var signatures []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		signatures = append(signatures, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bot signatures: %w", err)
+	}
+	return signatures, nil
+}
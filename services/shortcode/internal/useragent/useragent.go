@@ -0,0 +1,157 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+)
+
+// Info parsed user agent
+type Info struct {
+	Browser        string // Browser family, or the name of the bot
+	BrowserVersion string // Major version
+	OS             string
+	OSVersion      string
+	Device         string // One of the model.Device* classes
+}
+
+// pattern maps a user agent pattern to a name, the first submatch is the version
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// botPatterns known crawlers, link unfurlers and HTTP libraries, checked before browsers
+var botPatterns = []pattern{
+	{"Googlebot", regexp.MustCompile(`Googlebot(?:-\w+)?/?(\d+)?`)},
+	{"Bingbot", regexp.MustCompile(`bingbot/(\d+)`)},
+	{"Slackbot", regexp.MustCompile(`Slack(?:bot|-ImgProxy)(?:-LinkExpanding)?\s?(\d+)?`)},
+	{"Twitterbot", regexp.MustCompile(`Twitterbot/?(\d+)?`)},
+	{"Facebook", regexp.MustCompile(`facebookexternalhit/?(\d+)?|facebookcatalog`)},
+	{"LinkedInBot", regexp.MustCompile(`LinkedInBot/?(\d+)?`)},
+	{"Discordbot", regexp.MustCompile(`Discordbot/?(\d+)?`)},
+	{"TelegramBot", regexp.MustCompile(`TelegramBot`)},
+	{"WhatsApp", regexp.MustCompile(`WhatsApp/?(\d+)?`)},
+	{"Applebot", regexp.MustCompile(`Applebot/?(\d+)?`)},
+	{"YandexBot", regexp.MustCompile(`YandexBot/?(\d+)?`)},
+	{"Baiduspider", regexp.MustCompile(`Baiduspider/?(\d+)?`)},
+	{"DuckDuckBot", regexp.MustCompile(`DuckDuckBot/?(\d+)?`)},
+	{"HeadlessChrome", regexp.MustCompile(`HeadlessChrome/(\d+)`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+)`)},
+	{"Wget", regexp.MustCompile(`^Wget/(\d+)`)},
+	{"python-requests", regexp.MustCompile(`python-requests/(\d+)`)},
+	{"Go-http-client", regexp.MustCompile(`Go-http-client/(\d+)`)},
+	{"Other bot", regexp.MustCompile(`(?i)bot\b|crawl|spider|slurp|preview|fetcher|scanner`)},
+}
+
+// browserPatterns browsers, ordered so that browsers based on Chrome or Safari match before them
+var browserPatterns = []pattern{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Yandex Browser", regexp.MustCompile(`YaBrowser/(\d+)`)},
+	{"UC Browser", regexp.MustCompile(`UCBrowser/(\d+)`)},
+	{"WeChat", regexp.MustCompile(`MicroMessenger/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+)[\d.]* (?:Mobile/\S+ )?Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`MSIE (\d+)|Trident/.*rv:(\d+)`)},
+}
+
+var (
+	windowsVersionPattern = regexp.MustCompile(`Windows NT (\d+\.\d+)`)
+	iOSVersionPattern     = regexp.MustCompile(`(?:iPhone OS|CPU OS) (\d+)`)
+	androidVersionPattern = regexp.MustCompile(`Android (\d+)`)
+	macOSVersionPattern   = regexp.MustCompile(`Mac OS X (\d+[_.]\d+)`)
+
+	// windowsVersions marketing names of Windows NT versions, NT 10.0 covers Windows 10 and 11
+	windowsVersions = map[string]string{"10.0": "10", "6.3": "8.1", "6.2": "8", "6.1": "7", "6.0": "Vista", "5.1": "XP"}
+)
+
+// Parse parses a user agent string, unrecognized parts are left empty and the device is unknown
+func Parse(userAgent string) Info {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return Info{Device: model.DeviceUnknown}
+	}
+
+	info := Info{}
+	info.OS, info.OSVersion = parseOS(userAgent)
+
+	if name, version, ok := match(botPatterns, userAgent); ok {
+		info.Browser, info.BrowserVersion = name, version
+		info.Device = model.DeviceBot
+		return info
+	}
+
+	info.Browser, info.BrowserVersion, _ = match(browserPatterns, userAgent)
+	info.Device = parseDevice(userAgent, info.OS)
+	return info
+}
+
+// match returns the name and version of the first matching pattern
+func match(patterns []pattern, userAgent string) (string, string, bool) {
+	for _, p := range patterns {
+		submatches := p.re.FindStringSubmatch(userAgent)
+		if submatches == nil {
+			continue
+		}
+		for _, version := range submatches[1:] {
+			if version != "" {
+				return p.name, version, true
+			}
+		}
+		return p.name, "", true
+	}
+	return "", "", false
+}
+
+// parseOS returns the operating system family and version
+func parseOS(userAgent string) (string, string) {
+	switch {
+	case strings.Contains(userAgent, "Windows Phone"):
+		return "Windows Phone", ""
+	case strings.Contains(userAgent, "Windows"):
+		if m := windowsVersionPattern.FindStringSubmatch(userAgent); m != nil {
+			return "Windows", windowsVersions[m[1]]
+		}
+		return "Windows", ""
+	case strings.Contains(userAgent, "iPad"):
+		return "iPadOS", firstSubmatch(iOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPod"):
+		return "iOS", firstSubmatch(iOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "Android"):
+		return "Android", firstSubmatch(androidVersionPattern, userAgent)
+	case strings.Contains(userAgent, "CrOS"):
+		return "ChromeOS", ""
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		return "macOS", strings.Replace(firstSubmatch(macOSVersionPattern, userAgent), "_", ".", 1)
+	case strings.Contains(userAgent, "Linux"), strings.Contains(userAgent, "X11"):
+		return "Linux", ""
+	}
+	return "", ""
+}
+
+// parseDevice returns the device class of a browser user agent
+func parseDevice(userAgent, os string) string {
+	switch {
+	case os == "iPadOS", strings.Contains(userAgent, "Tablet"), strings.Contains(userAgent, "Kindle"), strings.Contains(userAgent, "Silk/"):
+		return model.DeviceTablet
+	case os == "Android" && !strings.Contains(userAgent, "Mobile"):
+		return model.DeviceTablet // Android tablets leave out "Mobile"
+	case strings.Contains(userAgent, "Mobi"), os == "iOS", os == "Windows Phone":
+		return model.DeviceMobile
+	case os != "":
+		return model.DeviceDesktop
+	}
+	return model.DeviceUnknown
+}
+
+// firstSubmatch returns the first submatch of the pattern or an empty string
+func firstSubmatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
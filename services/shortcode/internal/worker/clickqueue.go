@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+// Failed batches are written again after a short pause, e.g. after a deadlock or a database failover.
+// The transaction of a failed batch was rolled back, so retrying cannot count clicks twice.
+const (
+	flushAttempts   = 3
+	flushRetryDelay = time.Second // Doubles after each failed attempt
+)
+
+// ClickRecorder writes batches of click events
+type ClickRecorder interface {
+	RecordClicks(ctx context.Context, events []*model.ClickEvent) error
+}
+
+// ClickQueue bounded in-process click queue, a fixed pool of workers writes the events in batches
+type ClickQueue struct {
+	recorder ClickRecorder
+	cfg      config.ClickConfig
+	events   chan *model.ClickEvent
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex // Guards closing the channel against concurrent Enqueue
+	closed bool
+
+	enqueued  atomic.Uint64
+	dropped   atomic.Uint64
+	processed atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// NewClickQueue creates click queue and starts its workers
+func NewClickQueue(recorder ClickRecorder, cfg config.ClickConfig) *ClickQueue {
+	q := &ClickQueue{
+		recorder: recorder,
+		cfg:      cfg,
+		events:   make(chan *model.ClickEvent, cfg.QueueSize),
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q
+}
+
+// Enqueue adds a click event, waiting at most the enqueue timeout when the queue is full.
+// Reports false if the event was dropped.
+func (q *ClickQueue) Enqueue(_ context.Context, event *model.ClickEvent) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case q.events <- event:
+		q.enqueued.Add(1)
+		return true
+	default:
+	}
+
+	if q.cfg.EnqueueTimeout > 0 {
+		timer := time.NewTimer(q.cfg.EnqueueTimeout)
+		defer timer.Stop()
+
+		select {
+		case q.events <- event:
+			q.enqueued.Add(1)
+			return true
+		case <-timer.C:
+		}
+	}
+
+	q.dropped.Add(1)
+	return false
+}
+
+// Stats returns the queue counters
+func (q *ClickQueue) Stats() service.ClickSinkStats {
+	return service.ClickSinkStats{
+		Enqueued:  q.enqueued.Load(),
+		Dropped:   q.dropped.Load(),
+		Processed: q.processed.Load(),
+		Failed:    q.failed.Load(),
+		Pending:   len(q.events),
+	}
+}
+
+// Close stops accepting events and waits until the queued events are written or the context ends
+func (q *ClickQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work collects events into batches, flushing when a batch is full or the flush interval passes
+func (q *ClickQueue) work() {
+	defer q.wg.Done()
+
+	batch := make([]*model.ClickEvent, 0, q.cfg.BatchSize)
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-q.events:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= q.cfg.BatchSize {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush writes a batch, retrying failed attempts. Batches failing every attempt are logged and counted.
+func (q *ClickQueue) flush(batch []*model.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	delay := flushRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := q.record(batch)
+		if err == nil {
+			q.processed.Add(uint64(len(batch)))
+			return
+		}
+		if attempt == flushAttempts {
+			q.failed.Add(uint64(len(batch)))
+			slog.Error("Failed to record clicks", "count", len(batch), "attempts", attempt, "error", err)
+			return
+		}
+
+		slog.Warn("Failed to record clicks, will retry", "count", len(batch), "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// record writes a batch in one attempt
+func (q *ClickQueue) record(batch []*model.ClickEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return q.recorder.RecordClicks(ctx, batch)
+}
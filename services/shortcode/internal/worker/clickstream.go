@@ -0,0 +1,261 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+	"github.com/redis/go-redis/v9"
+)
+
+// streamEventField stream entry field holding the JSON encoded click event
+const streamEventField = "event"
+
+// pruneInterval time between removals of old processed event IDs
+const pruneInterval = time.Hour
+
+// ClickStream publishes click events to a Redis Stream, where they survive restarts of the server
+type ClickStream struct {
+	redisClient *redis.Client
+	cfg         config.ClickConfig
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// NewClickStream creates click stream publisher
+func NewClickStream(redisClient *redis.Client, cfg config.ClickConfig) *ClickStream {
+	return &ClickStream{
+		redisClient: redisClient,
+		cfg:         cfg,
+	}
+}
+
+// Enqueue appends a click event to the stream, reports false if the event was dropped
+func (s *ClickStream) Enqueue(ctx context.Context, event *model.ClickEvent) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		s.dropped.Add(1)
+		slog.ErrorContext(ctx, "Failed to encode click event", "error", err)
+		return false
+	}
+
+	// Publish even if the client is already gone, the request span stays the parent of the command
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.PublishTimeout)
+	defer cancel()
+
+	err = s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.cfg.Stream,
+		MaxLen: s.cfg.StreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{streamEventField: data},
+	}).Err()
+	if err != nil {
+		s.dropped.Add(1)
+		slog.ErrorContext(ctx, "Failed to publish click event", "error", err)
+		return false
+	}
+
+	s.enqueued.Add(1)
+	return true
+}
+
+// Stats returns the publisher counters, the consumer side is tracked by the consumer group
+func (s *ClickStream) Stats() service.ClickSinkStats {
+	return service.ClickSinkStats{
+		Enqueued: s.enqueued.Load(),
+		Dropped:  s.dropped.Load(),
+	}
+}
+
+// ClickConsumer reads click events from the stream as a member of the consumer group.
+// Events are acknowledged only after they were recorded; unacknowledged events of crashed
+// consumers are claimed after the claim idle time and recorded again, skipping those already saved.
+type ClickConsumer struct {
+	redisClient *redis.Client
+	recorder    ClickRecorder
+	repo        repository.ShortCodeRepository
+	cfg         config.ClickConfig
+	name        string
+
+	lastPrune time.Time
+}
+
+// NewClickConsumer creates click stream consumer, named after the host and process
+func NewClickConsumer(redisClient *redis.Client, recorder ClickRecorder, repo repository.ShortCodeRepository, cfg config.ClickConfig) *ClickConsumer {
+	hostname, _ := os.Hostname()
+	return &ClickConsumer{
+		redisClient: redisClient,
+		recorder:    recorder,
+		repo:        repo,
+		cfg:         cfg,
+		name:        fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Run consumes click events until the context is canceled
+func (c *ClickConsumer) Run(ctx context.Context) {
+	slog.Info("Click consumer started", "consumer", c.name, "stream", c.cfg.Stream, "group", c.cfg.ConsumerGroup)
+
+	for ctx.Err() == nil {
+		if err := c.ensureGroup(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to create click consumer group", "error", err)
+			c.wait(ctx)
+			continue
+		}
+
+		if err := c.consume(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Click consumer failed", "error", err)
+			c.wait(ctx)
+		}
+	}
+
+	slog.Info("Click consumer stopped", "consumer", c.name)
+}
+
+// ensureGroup creates the stream and its consumer group if they do not exist yet
+func (c *ClickConsumer) ensureGroup(ctx context.Context) error {
+	err := c.redisClient.XGroupCreateMkStream(ctx, c.cfg.Stream, c.cfg.ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// consume alternates between claiming stale events and reading new ones
+func (c *ClickConsumer) consume(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if err := c.claimStale(ctx); err != nil {
+			return err
+		}
+
+		streams, err := c.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.cfg.ConsumerGroup,
+			Consumer: c.name,
+			Streams:  []string{c.cfg.Stream, ">"},
+			Count:    int64(c.cfg.BatchSize),
+			Block:    c.cfg.FlushInterval,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		for _, stream := range streams {
+			c.process(stream.Messages)
+		}
+
+		c.prune(ctx)
+	}
+	return nil
+}
+
+// claimStale takes over events left unacknowledged longer than the claim idle time
+func (c *ClickConsumer) claimStale(ctx context.Context) error {
+	start := "0-0"
+	for {
+		messages, next, err := c.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.ConsumerGroup,
+			Consumer: c.name,
+			MinIdle:  c.cfg.ClaimIdle,
+			Start:    start,
+			Count:    int64(c.cfg.BatchSize),
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(messages) > 0 {
+			slog.InfoContext(ctx, "Click consumer claimed stale events", "consumer", c.name, "count", len(messages))
+			c.process(messages)
+		}
+		if next == "0-0" || len(messages) == 0 {
+			return nil
+		}
+		start = next
+	}
+}
+
+// process records a batch of stream entries and acknowledges them once they are saved.
+// Failed batches stay pending and are retried after the claim idle time.
+func (c *ClickConsumer) process(messages []redis.XMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	// Finish the batch even if the consumer is stopped meanwhile
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ids := make([]string, 0, len(messages))
+	events := make([]*model.ClickEvent, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+
+		event, err := decodeClickEvent(message)
+		if err != nil {
+			slog.Warn("Skipping malformed click event", "id", message.ID, "error", err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if len(events) > 0 {
+		if err := c.recorder.RecordClicks(ctx, events); err != nil {
+			slog.Error("Failed to record clicks, will retry", "count", len(events), "error", err)
+			return
+		}
+	}
+
+	if err := c.redisClient.XAck(ctx, c.cfg.Stream, c.cfg.ConsumerGroup, ids...).Err(); err != nil {
+		slog.Error("Failed to acknowledge clicks", "count", len(ids), "error", err)
+	}
+}
+
+// prune removes processed event IDs older than the dedup retention, at most once per prune interval
+func (c *ClickConsumer) prune(ctx context.Context) {
+	if time.Since(c.lastPrune) < pruneInterval {
+		return
+	}
+	c.lastPrune = time.Now()
+
+	pruned, err := c.repo.PruneProcessedEvents(ctx, time.Now().Add(-c.cfg.DedupRetention))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to prune processed click events", "error", err)
+	} else if pruned > 0 {
+		slog.InfoContext(ctx, "Pruned processed click events", "count", pruned)
+	}
+}
+
+// wait pauses before retrying after an error
+func (c *ClickConsumer) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// decodeClickEvent decodes a stream entry, the entry ID becomes the event ID used for deduplication
+func decodeClickEvent(message redis.XMessage) (*model.ClickEvent, error) {
+	data, ok := message.Values[streamEventField].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %s field", streamEventField)
+	}
+
+	var event model.ClickEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil, err
+	}
+	event.ID = message.ID
+	return &event, nil
+}
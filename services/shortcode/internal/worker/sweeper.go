@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+)
+
+// LinkExpirer handles a batch of expired short links, implemented by the short link service
+type LinkExpirer interface {
+	ExpireShortCodes(ctx context.Context, shortCodes []model.ShortCode, mode string) error
+}
+
+// ExpirySweeper periodically evicts expired short links from the cache and optionally removes them
+type ExpirySweeper struct {
+	repo    repository.ShortCodeRepository
+	expirer LinkExpirer
+	cfg     config.SweeperConfig
+
+	// Last link handled, in mode none expired links stay in the table and are skipped on later runs
+	watermark *model.ExpiryCursor
+}
+
+// NewExpirySweeper creates expiry sweeper
+func NewExpirySweeper(repo repository.ShortCodeRepository, expirer LinkExpirer, cfg config.SweeperConfig) *ExpirySweeper {
+	return &ExpirySweeper{
+		repo:    repo,
+		expirer: expirer,
+		cfg:     cfg,
+	}
+}
+
+// Run sweeps on every interval until the context is canceled
+func (s *ExpirySweeper) Run(ctx context.Context) {
+	if s.cfg.Interval <= 0 {
+		slog.Warn("Expiry sweeper disabled, link.expired is only sent for expired links that are visited")
+		return
+	}
+
+	slog.Info("Expiry sweeper started", "interval", s.cfg.Interval, "batch", s.cfg.BatchSize, "mode", s.cfg.Mode)
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if swept, err := s.Sweep(ctx); err != nil {
+			slog.ErrorContext(ctx, "Expiry sweep failed", "error", err)
+		} else if swept > 0 {
+			slog.InfoContext(ctx, "Expiry sweep handled expired links", "count", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Expiry sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep handles all links expired so far in batches, returns the number of links handled
+func (s *ExpirySweeper) Sweep(ctx context.Context) (int, error) {
+	now := time.Now()
+	swept := 0
+
+	for {
+		shortCodes, err := s.repo.ListExpired(ctx, now, s.watermark, s.cfg.BatchSize)
+		if err != nil {
+			return swept, err
+		}
+		if len(shortCodes) == 0 {
+			return swept, nil
+		}
+
+		if err := s.expirer.ExpireShortCodes(ctx, shortCodes, s.cfg.Mode); err != nil {
+			return swept, err
+		}
+		swept += len(shortCodes)
+
+		last := shortCodes[len(shortCodes)-1]
+		s.watermark = &model.ExpiryCursor{ExpiresAt: *last.ExpiresAt, ID: last.ID}
+
+		if len(shortCodes) < s.cfg.BatchSize {
+			return swept, nil
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lincyaw/tools/services/shortcode/internal/config"
+	"github.com/lincyaw/tools/services/shortcode/internal/metrics"
+	"github.com/lincyaw/tools/services/shortcode/internal/model"
+	"github.com/lincyaw/tools/services/shortcode/internal/repository"
+	"github.com/lincyaw/tools/services/shortcode/internal/service"
+)
+
+const (
+	// deliveriesPerWorker deliveries claimed per worker and poll
+	deliveriesPerWorker = 10
+	// deliveryLeaseMargin time added to the request timeouts of a claim before its deliveries are due again
+	deliveryLeaseMargin = 30 * time.Second
+	// webhookPruneInterval how often old deliveries and dead letters are removed
+	webhookPruneInterval = time.Hour
+	// maxErrorLength bytes of the response body or error kept in the delivery log
+	maxErrorLength = 500
+	// clickBufferSize click batches waiting for the batcher before new ones are dropped
+	clickBufferSize = 1024
+	// linkEventBufferSize lifecycle events waiting for the batcher before publishers queue them themselves
+	linkEventBufferSize = 4096
+	// maxLinkEventsPerFlush lifecycle events queued together, e.g. the links of a bulk create
+	maxLinkEventsPerFlush = 1000
+)
+
+// errPrivateTarget webhook host resolves to an address that is not public
+var errPrivateTarget = errors.New("webhook target is not a public address")
+
+// WebhookBatcher queues webhook deliveries for short link events off the request path. Lifecycle
+// events are queued as soon as the batcher gets to them, together with those published meanwhile;
+// clicks are collected and sent as one link.clicked event per batch window.
+type WebhookBatcher struct {
+	webhooks service.WebhookService
+	cfg      config.WebhookConfig
+	links    chan model.LinkEvent
+	clicks   chan []model.RecordedClick
+	done     chan struct{}
+
+	mu     sync.RWMutex // Guards closing the channels against concurrent publishers
+	closed bool
+}
+
+// NewWebhookBatcher creates webhook batcher and starts collecting clicks
+func NewWebhookBatcher(webhooks service.WebhookService, cfg config.WebhookConfig) *WebhookBatcher {
+	b := &WebhookBatcher{
+		webhooks: webhooks,
+		cfg:      cfg,
+		links:    make(chan model.LinkEvent, linkEventBufferSize),
+		clicks:   make(chan []model.RecordedClick, clickBufferSize),
+		done:     make(chan struct{}),
+	}
+	go b.collect()
+	return b
+}
+
+// PublishLinkEvent hands a link lifecycle event to the batcher. If the batcher is behind or closed the
+// event is queued right here, lifecycle events are not dropped.
+func (b *WebhookBatcher) PublishLinkEvent(ctx context.Context, eventType string, link *model.ShortCodeInfo) {
+	event := model.LinkEvent{Type: eventType, Link: link}
+
+	b.mu.RLock()
+	if !b.closed {
+		select {
+		case b.links <- event:
+			b.mu.RUnlock()
+			return
+		default:
+		}
+	}
+	b.mu.RUnlock()
+
+	// The link changed already, its event is queued even if the request is canceled meanwhile
+	b.flushLinks(context.WithoutCancel(ctx), []model.LinkEvent{event})
+}
+
+// PublishClicks adds recorded clicks to the current batch, they are dropped if the batcher is behind
+func (b *WebhookBatcher) PublishClicks(ctx context.Context, clicks []model.RecordedClick) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	select {
+	case b.clicks <- clicks:
+	default:
+		slog.WarnContext(ctx, "Webhook click buffer full, dropping clicks", "count", len(clicks))
+	}
+}
+
+// Close stops accepting events and waits until the pending events are queued or the context ends
+func (b *WebhookBatcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.links)
+		close(b.clicks)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collect queues lifecycle events as they come and gathers clicks, flushing them when the batch is
+// full or the batch window passes
+func (b *WebhookBatcher) collect() {
+	defer close(b.done)
+
+	batch := make([]model.RecordedClick, 0, b.cfg.ClickBatchSize)
+	ticker := time.NewTicker(b.cfg.ClickBatchWindow)
+	defer ticker.Stop()
+
+	links, clicks := b.links, b.clicks
+	for links != nil || clicks != nil {
+		select {
+		case event, ok := <-links:
+			if !ok {
+				links = nil
+				continue
+			}
+			b.flushLinks(context.Background(), b.drainLinks(event))
+		case newClicks, ok := <-clicks:
+			if !ok {
+				clicks = nil
+				continue
+			}
+			batch = append(batch, newClicks...)
+			if len(batch) >= b.cfg.ClickBatchSize {
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+	b.flush(batch)
+}
+
+// drainLinks collects the lifecycle events already waiting behind the first one, up to the flush limit
+func (b *WebhookBatcher) drainLinks(first model.LinkEvent) []model.LinkEvent {
+	events := []model.LinkEvent{first}
+	for len(events) < maxLinkEventsPerFlush {
+		select {
+		case event, ok := <-b.links:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+	return events
+}
+
+// flushLinks queues the deliveries of lifecycle events, failures are logged but not retried
+func (b *WebhookBatcher) flushLinks(ctx context.Context, events []model.LinkEvent) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := b.webhooks.DispatchLinkEvents(ctx, events); err != nil {
+		slog.ErrorContext(ctx, "Failed to queue webhook events", "count", len(events), "error", err)
+	}
+}
+
+// flush queues the link.clicked deliveries of a batch, failed batches are logged but not retried
+func (b *WebhookBatcher) flush(batch []model.RecordedClick) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := b.webhooks.DispatchClicks(ctx, batch); err != nil {
+		slog.Error("Failed to queue webhook clicks", "count", len(batch), "error", err)
+	}
+}
+
+// WebhookDispatcher sends queued webhook deliveries, retrying failures with exponential backoff
+// and moving deliveries that failed on every attempt to the dead letters
+type WebhookDispatcher struct {
+	repo   repository.WebhookRepository
+	cfg    config.WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookDispatcher creates webhook dispatcher
+func NewWebhookDispatcher(repo repository.WebhookRepository, cfg config.WebhookConfig) *WebhookDispatcher {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		// Checked on every connection, so host names resolving to internal addresses are refused as well
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil || !service.IsPublicIP(host) {
+				return errPrivateTarget
+			}
+			return nil
+		}
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookDispatcher{
+		repo: repo,
+		cfg:  cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			// Redirects are reported as failures, following them could reach other hosts
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run sends due deliveries on every poll interval until the context is canceled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	slog.Info("Webhook dispatcher started", "workers", d.cfg.Workers, "poll_interval", d.cfg.PollInterval)
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		// Keep going while full batches are claimed, so a backlog does not wait for the next tick
+		for ctx.Err() == nil {
+			claimed, err := d.dispatchDue(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+				break
+			}
+			if claimed < d.claimLimit() {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) >= webhookPruneInterval {
+			d.prune(ctx)
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimLimit deliveries claimed per poll
+func (d *WebhookDispatcher) claimLimit() int {
+	return d.cfg.Workers * deliveriesPerWorker
+}
+
+// dispatchDue claims the due deliveries and sends them concurrently, returns the number claimed
+func (d *WebhookDispatcher) dispatchDue(ctx context.Context) (int, error) {
+	// Each worker sends its share of the claim one after the other, all of them may time out
+	lease := d.cfg.Timeout*deliveriesPerWorker + deliveryLeaseMargin
+	deliveries, err := d.repo.ClaimDueDeliveries(ctx, time.Now(), lease, d.claimLimit())
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.WebhookID)
+	}
+	list, err := d.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	webhooks := make(map[uint]*model.Webhook, len(list))
+	for i := range list {
+		webhooks[list[i].ID] = &list[i]
+	}
+
+	// Attempts already started are finished and recorded even if the dispatcher is stopped
+	sendCtx := context.WithoutCancel(ctx)
+	slots := make(chan struct{}, d.cfg.Workers)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		webhook, ok := webhooks[deliveries[i].WebhookID]
+		if !ok {
+			continue // Deleted since the claim
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery *model.WebhookDelivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			d.deliver(sendCtx, delivery, webhook)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt and records its outcome: succeeded, retried later or dead
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery, webhook *model.Webhook) {
+	now := time.Now()
+	status, err := d.send(ctx, delivery, webhook, now)
+
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	if err == nil {
+		delivered := time.Now()
+		delivery.Status = model.DeliverySucceeded
+		delivery.DeliveredAt = &delivered
+		delivery.LastError = ""
+		if err := d.repo.SaveDeliveryResult(ctx, delivery); err != nil {
+			slog.ErrorContext(ctx, "Failed to save webhook delivery", "delivery", delivery.ID, "error", err)
+		}
+		metrics.WebhookDeliveries.WithLabelValues("succeeded").Inc()
+		return
+	}
+
+	delivery.LastError = truncate(err.Error(), maxErrorLength)
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		if err := d.repo.MoveToDeadLetters(ctx, delivery, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to move webhook delivery to dead letters", "delivery", delivery.ID, "error", err)
+		}
+		slog.WarnContext(ctx, "Webhook delivery failed on every attempt", "webhook", webhook.ID, "delivery", delivery.ID,
+			"event", delivery.EventType, "attempts", delivery.Attempts, "error", delivery.LastError)
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		return
+	}
+
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	if err := d.repo.SaveDeliveryResult(ctx, delivery); err != nil {
+		slog.ErrorContext(ctx, "Failed to save webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+	metrics.WebhookDeliveries.WithLabelValues("retry").Inc()
+}
+
+// send posts the signed payload, returns the response status (0 without response) and an error unless it is 2xx
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *model.WebhookDelivery, webhook *model.Webhook, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shortcode-webhooks/1.0")
+	req.Header.Set("X-Shortcode-Event", delivery.EventType)
+	req.Header.Set("X-Shortcode-Event-ID", delivery.EventID)
+	req.Header.Set("X-Shortcode-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Shortcode-Timestamp", timestamp)
+	req.Header.Set("X-Shortcode-Signature", "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Let the connection be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff delay before the next attempt, doubling from the retry base up to the retry max, with jitter
+// so receivers coming back from an outage are not hit by every retry at once
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryMax
+	if shift := attempts - 1; shift < 30 {
+		delay = min(d.cfg.RetryBase<<shift, d.cfg.RetryMax)
+	}
+	return delay + rand.N(delay/10+1)
+}
+
+// prune removes deliveries and dead letters older than the retention
+func (d *WebhookDispatcher) prune(ctx context.Context) {
+	pruned, err := d.repo.PruneDeliveries(ctx, time.Now().Add(-d.cfg.Retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to prune webhook deliveries", "error", err)
+		return
+	}
+	if pruned > 0 {
+		slog.InfoContext(ctx, "Pruned webhook deliveries", "count", pruned)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.body" with the webhook secret, receivers compute
+// the same value to check the X-Shortcode-Signature header
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// truncate shortens s to at most n bytes, dropping invalid UTF-8 and NUL bytes the database would reject
+func truncate(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.ReplaceAll(strings.ToValidUTF8(s, ""), "\x00", "")
+}